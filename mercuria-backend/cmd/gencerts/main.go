@@ -0,0 +1,111 @@
+// Command gencerts creates a development CA and issues mTLS certificates for
+// each service, in the layout mtls.Config expects:
+//
+//	certs/ca/ca.{crt,key}
+//	certs/<service>/service.{crt,key}  server certificate
+//	certs/<service>/client.{crt,key}   client certificate
+//
+// An existing CA is reused, so rerunning it renews service certificates
+// without breaking trust in the ones still deployed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/devca"
+)
+
+func main() {
+	out := flag.String("out", "certs", "output directory")
+	services := flag.String("services", "auth,wallet,transaction,ledger,analytics", "comma-separated service names")
+	hosts := flag.String("hosts", "", "comma-separated extra DNS names for every service certificate")
+	trustDomain := flag.String("trust-domain", devca.DefaultTrustDomain, "SPIFFE trust domain")
+	caLifetime := flag.Duration("ca-lifetime", devca.DefaultCALifetime, "CA certificate lifetime")
+	certLifetime := flag.Duration("cert-lifetime", devca.DefaultCertLifetime, "service certificate lifetime")
+	newCA := flag.Bool("new-ca", false, "replace an existing CA (invalidates all issued certificates)")
+	flag.Parse()
+
+	ca, err := loadOrCreateCA(*out, *caLifetime, *newCA)
+	if err != nil {
+		log.Fatalf("Failed to set up CA: %v", err)
+	}
+
+	for _, service := range splitList(*services) {
+		// Docker Compose reaches services by container name
+		dnsNames := append([]string{"mercuria-" + service}, splitList(*hosts)...)
+
+		for _, kind := range []struct {
+			name  string
+			usage devca.Usage
+		}{
+			{"service", devca.UsageServer},
+			{"client", devca.UsageClient},
+		} {
+			cert, err := ca.Issue(devca.IssueOptions{
+				Service:     service,
+				DNSNames:    dnsNames,
+				TrustDomain: *trustDomain,
+				Lifetime:    *certLifetime,
+				Usage:       kind.usage,
+			})
+			if err != nil {
+				log.Fatalf("Failed to issue %s certificate for %s: %v", kind.name, service, err)
+			}
+
+			dir := filepath.Join(*out, service)
+			if err := cert.WriteFiles(filepath.Join(dir, kind.name+".crt"), filepath.Join(dir, kind.name+".key")); err != nil {
+				log.Fatalf("Failed to write %s certificate for %s: %v", kind.name, service, err)
+			}
+		}
+
+		fmt.Printf("✓ %s (expires %s)\n", service, certExpiry(ca.Cert.NotAfter, *certLifetime))
+	}
+}
+
+func loadOrCreateCA(out string, lifetime time.Duration, replace bool) (*devca.CA, error) {
+	certPath := filepath.Join(out, "ca", "ca.crt")
+	keyPath := filepath.Join(out, "ca", "ca.key")
+
+	if !replace {
+		if _, err := os.Stat(certPath); err == nil {
+			fmt.Printf("Using existing CA %s\n", certPath)
+			return devca.Load(certPath, keyPath)
+		}
+	}
+
+	ca, err := devca.New(lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if err := ca.WriteFiles(certPath, keyPath); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Created CA %s (expires %s)\n", certPath, ca.Cert.NotAfter.Format(time.DateOnly))
+	return ca, nil
+}
+
+// certExpiry is when issued certificates expire; they never outlive the CA
+func certExpiry(caExpiry time.Time, lifetime time.Duration) string {
+	expiry := time.Now().Add(lifetime)
+	if caExpiry.Before(expiry) {
+		expiry = caExpiry
+	}
+	return expiry.Format(time.DateOnly)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
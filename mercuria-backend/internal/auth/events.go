@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/kmassidik/mercuria/pkg/outbox"
+)
+
+// Security event types, each published to the Kafka topic of the same name
+const (
+	EventLoginFailed = "user.login_failed"
+	EventUserLocked  = "user.locked"
+	EventMFAEnabled  = "user.mfa_enabled"
+)
+
+// SecurityEvents records security events for audit
+type SecurityEvents interface {
+	Emit(ctx context.Context, eventType, aggregateID string, payload map[string]interface{}) error
+}
+
+// OutboxSecurityEvents saves security events to the outbox, from where the
+// outbox publisher sends them to Kafka
+type OutboxSecurityEvents struct {
+	db   *sql.DB
+	repo *outbox.Repository
+}
+
+func NewOutboxSecurityEvents(db *sql.DB, repo *outbox.Repository) *OutboxSecurityEvents {
+	return &OutboxSecurityEvents{db: db, repo: repo}
+}
+
+// Emit saves one event in its own transaction; a failed login has no
+// business transaction to join
+func (e *OutboxSecurityEvents) Emit(ctx context.Context, eventType, aggregateID string, payload map[string]interface{}) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event := &outbox.OutboxEvent{
+		AggregateID: aggregateID,
+		EventType:   eventType,
+		Topic:       eventType,
+		Payload:     payload,
+	}
+	if err := e.repo.SaveEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit security event: %w", err)
+	}
+	return nil
+}
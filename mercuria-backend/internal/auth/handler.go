@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/jwks"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+)
+
+// Handler exposes the login, two-factor and token endpoints of the auth service
+type Handler struct {
+	tokens *TokenService
+	login  *LoginService
+	mfa    *MFAService
+	logger *logger.Logger
+}
+
+// NewHandler creates the handler. login and mfa may be nil, for a service
+// that only refreshes and revokes tokens.
+func NewHandler(tokens *TokenService, login *LoginService, mfa *MFAService, log *logger.Logger) *Handler {
+	return &Handler{
+		tokens: tokens,
+		login:  login,
+		mfa:    mfa,
+		logger: log,
+	}
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type stepUpRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RegisterRoutes mounts the token endpoints. requireAuth is the JWTAuth
+// middleware; logout needs the caller's access token to revoke it.
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, requireAuth func(http.Handler) http.Handler) {
+	if h.login != nil {
+		mux.HandleFunc("POST /api/v1/login", h.Login)
+		mux.HandleFunc("POST /api/v1/login/mfa", h.LoginMFA)
+	}
+	if h.mfa != nil {
+		mux.Handle("POST /api/v1/mfa/totp/enroll", requireAuth(http.HandlerFunc(h.EnrollTOTP)))
+		mux.Handle("POST /api/v1/mfa/totp/confirm", requireAuth(http.HandlerFunc(h.ConfirmTOTP)))
+	}
+	if h.login != nil && h.mfa != nil {
+		mux.Handle("POST /api/v1/mfa/step-up", requireAuth(http.HandlerFunc(h.StepUp)))
+	}
+	mux.HandleFunc("POST /api/v1/token/refresh", h.Refresh)
+	mux.Handle("POST /api/v1/logout", requireAuth(http.HandlerFunc(h.Logout)))
+	mux.Handle("POST /api/v1/logout/all", requireAuth(http.HandlerFunc(h.LogoutAll)))
+
+	if h.tokens.keys != nil {
+		mux.Handle("GET "+jwks.WellKnownPath, h.tokens.keys.Handler())
+	}
+}
+
+// Login verifies an email and password and returns a token pair
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" || req.Password == "" {
+		apierror.Write(w, r, apierror.BadRequest("email and password are required"))
+		return
+	}
+
+	result, err := h.login.Login(r.Context(), req.Email, req.Password, middleware.ClientIP(r))
+	if h.writeLoginError(w, r, err) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// LoginMFA completes a login with the MFA token from Login and a TOTP or recovery code
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req mfaLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		apierror.Write(w, r, apierror.BadRequest("mfa_token and code or recovery_code are required"))
+		return
+	}
+
+	result, err := h.login.VerifyMFA(r.Context(), req.MFAToken, req.Code, req.RecoveryCode, middleware.ClientIP(r))
+	if h.writeLoginError(w, r, err) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeLoginError writes the response for a failed login, reporting whether err was one
+func (h *Handler) writeLoginError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var throttled *ThrottledError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &throttled):
+		apierror.Write(w, r, apierror.RateLimited(throttled.Error(), throttled.RetryAfter))
+	case errors.Is(err, ErrInvalidCredentials):
+		apierror.Write(w, r, apierror.Unauthorized("invalid email or password"))
+	case errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrInvalidMFAToken):
+		apierror.Write(w, r, apierror.Unauthorized(err.Error()))
+	default:
+		h.logger.ErrorContext(r.Context(), "Failed to log in", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+	}
+	return true
+}
+
+// EnrollTOTP starts TOTP enrolment, returning the secret to add to an authenticator app
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+		return
+	}
+
+	pending, err := h.mfa.BeginEnrollment(r.Context(), claims.UserID, claims.Email)
+	if errors.Is(err, ErrMFAAlreadyEnrolled) {
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to begin TOTP enrolment", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// ConfirmTOTP enables TOTP with a first valid code and returns the recovery codes
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		apierror.Write(w, r, apierror.BadRequest("code is required"))
+		return
+	}
+
+	codes, err := h.mfa.ConfirmEnrollment(r.Context(), claims.UserID, req.Code)
+	switch {
+	case errors.Is(err, ErrNoPendingMFA):
+		apierror.Write(w, r, apierror.Conflict(err.Error()))
+		return
+	case errors.Is(err, ErrInvalidMFACode):
+		apierror.Write(w, r, apierror.Validation(apierror.FieldError{Field: "code", Code: "invalid", Message: err.Error()}))
+		return
+	case err != nil:
+		h.logger.ErrorContext(r.Context(), "Failed to confirm TOTP enrolment", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+// StepUp verifies a second factor for the caller's session and returns
+// rotated tokens that pass step-up checks
+func (h *Handler) StepUp(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+		return
+	}
+
+	var req stepUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		apierror.Write(w, r, apierror.BadRequest("refresh_token and code or recovery_code are required"))
+		return
+	}
+
+	pair, err := h.login.StepUp(r.Context(), req.RefreshToken, claims, req.Code, req.RecoveryCode, middleware.ClientIP(r))
+	switch {
+	case errors.Is(err, ErrMFANotEnrolled):
+		apierror.Write(w, r, apierror.Forbidden(err.Error()))
+		return
+	case errors.Is(err, ErrRefreshTokenReused):
+		apierror.Write(w, r, apierror.Unauthorized("refresh token reuse detected, please log in again"))
+		return
+	case errors.Is(err, ErrInvalidRefreshToken):
+		apierror.Write(w, r, apierror.Unauthorized("invalid or expired refresh token"))
+		return
+	}
+	if h.writeLoginError(w, r, err) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pair)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		apierror.Write(w, r, apierror.BadRequest("refresh_token is required"))
+		return
+	}
+
+	pair, err := h.tokens.Refresh(r.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, ErrRefreshTokenReused):
+		apierror.Write(w, r, apierror.Unauthorized("refresh token reuse detected, please log in again"))
+		return
+	case errors.Is(err, ErrInvalidRefreshToken):
+		apierror.Write(w, r, apierror.Unauthorized("invalid or expired refresh token"))
+		return
+	case err != nil:
+		h.logger.ErrorContext(r.Context(), "Failed to refresh token", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pair)
+}
+
+// Logout ends the session of the given refresh token
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		apierror.Write(w, r, apierror.BadRequest("refresh_token is required"))
+		return
+	}
+
+	claims, _ := middleware.GetClaimsFromContext(r.Context())
+
+	err := h.tokens.Logout(r.Context(), req.RefreshToken, claims)
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		apierror.Write(w, r, apierror.Unauthorized("invalid or expired refresh token"))
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to log out", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll ends every session of the caller, on all devices
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+		return
+	}
+
+	if err := h.tokens.LogoutAll(r.Context(), claims.UserID, claims); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to log out all sessions", "error", err)
+		apierror.Write(w, r, apierror.Internal(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
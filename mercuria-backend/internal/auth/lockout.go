@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+)
+
+// ThrottledError is returned while an account or IP may not attempt to log in
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // Locked out, rather than waiting out a progressive delay
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account temporarily locked, retry after %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// FailureResult describes the state after a failed login
+type FailureResult struct {
+	AccountFailures int64
+	IPFailures      int64
+	Locked          bool          // This failure locked the account
+	RetryAfter      time.Duration // Wait imposed on the account, if any
+}
+
+// recordFailureScript counts a failed login for an account. It imposes the
+// progressive delay, or locks the account once the failure limit is reached.
+// KEYS: failures, delay, lock. ARGV: window, max failures, lockout,
+// delay after, delay base, delay max (durations in ms).
+// Returns {failures, locked, wait_ms}.
+var recordFailureScript = goredis.NewScript(`
+local failures = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if failures >= tonumber(ARGV[2]) then
+	redis.call('SET', KEYS[3], '1', 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1], KEYS[2])
+	return {failures, 1, tonumber(ARGV[3])}
+end
+local excess = failures - tonumber(ARGV[4])
+if excess < 0 then
+	return {failures, 0, 0}
+end
+local wait = math.min(tonumber(ARGV[5]) * 2 ^ excess, tonumber(ARGV[6]))
+wait = math.floor(wait)
+if wait > 0 then
+	redis.call('SET', KEYS[2], '1', 'PX', wait)
+end
+return {failures, 0, wait}
+`)
+
+// countScript increments a counter, setting its TTL on first increment
+var countScript = goredis.NewScript(`
+local v = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return v
+`)
+
+// LoginGuard tracks failed logins per account and per IP in Redis
+type LoginGuard struct {
+	rdb *redis.Client
+	cfg config.LockoutConfig
+}
+
+func NewLoginGuard(rdb *redis.Client, cfg config.LockoutConfig) *LoginGuard {
+	return &LoginGuard{rdb: rdb, cfg: cfg}
+}
+
+// accountKey identifies an account by a hash of its email, so login attempts
+// for unknown emails are throttled too and no addresses end up in Redis keys
+func (g *LoginGuard) accountKey(kind, email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return g.rdb.Keys().Key("login", kind, redis.Tag(hex.EncodeToString(sum[:16])))
+}
+
+func (g *LoginGuard) ipKey(ip string) string {
+	return g.rdb.Keys().Key("login", "ip_failures", redis.Tag(ip))
+}
+
+// Check returns a *ThrottledError if the account or IP may not log in now
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	var lockTTL, delayTTL *goredis.DurationCmd
+	var ipFailures *goredis.StringCmd
+
+	_, err := g.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		lockTTL = pipe.PTTL(ctx, g.accountKey("lock", email))
+		delayTTL = pipe.PTTL(ctx, g.accountKey("delay", email))
+		ipFailures = pipe.Get(ctx, g.ipKey(ip))
+		return nil
+	})
+	if err != nil && err != goredis.Nil {
+		return fmt.Errorf("failed to check login throttling: %w", err)
+	}
+
+	if ttl := lockTTL.Val(); ttl > 0 {
+		return &ThrottledError{RetryAfter: ttl, Locked: true}
+	}
+	if ttl := delayTTL.Val(); ttl > 0 {
+		return &ThrottledError{RetryAfter: ttl}
+	}
+
+	if n, _ := ipFailures.Int(); g.cfg.MaxIPFailures > 0 && n >= g.cfg.MaxIPFailures {
+		ttl, err := g.rdb.PTTL(ctx, g.ipKey(ip)).Result()
+		if err != nil || ttl <= 0 {
+			ttl = g.cfg.FailureWindow
+		}
+		return &ThrottledError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login for the account and IP
+func (g *LoginGuard) RecordFailure(ctx context.Context, email, ip string) (*FailureResult, error) {
+	keys := []string{g.accountKey("failures", email), g.accountKey("delay", email), g.accountKey("lock", email)}
+	res, err := recordFailureScript.Run(ctx, g.rdb, keys,
+		g.cfg.FailureWindow.Milliseconds(), g.cfg.MaxFailures, g.cfg.LockoutDuration.Milliseconds(),
+		g.cfg.DelayAfter, g.cfg.DelayBase.Milliseconds(), g.cfg.DelayMax.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	ipFailures, err := countScript.Run(ctx, g.rdb, []string{g.ipKey(ip)}, g.cfg.FailureWindow.Milliseconds()).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return &FailureResult{
+		AccountFailures: res[0],
+		IPFailures:      ipFailures,
+		Locked:          res[1] == 1,
+		RetryAfter:      time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// RecordSuccess clears the account's failures. IP failures are kept, since
+// one valid login must not reset a spraying attacker's count.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	err := g.rdb.Del(ctx, g.accountKey("failures", email), g.accountKey("delay", email)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// Credentials is what the auth service needs to verify a login
+type Credentials struct {
+	UserID       string
+	Email        string
+	PasswordHash string
+	Roles        []string
+}
+
+// UserStore looks up login credentials; it returns ErrUserNotFound for
+// unknown emails
+type UserStore interface {
+	GetCredentials(ctx context.Context, email string) (*Credentials, error)
+}
+
+// LoginResult is either a token pair, or for users with two-factor
+// authentication an MFA token to exchange together with a code
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// LoginService verifies passwords behind the login guard and issues tokens
+type LoginService struct {
+	users     UserStore
+	passwords *PasswordPolicy
+	guard     *LoginGuard
+	tokens    *TokenService
+	mfa       *MFAService // nil disables two-factor authentication
+	events    SecurityEvents
+	logger    *logger.Logger
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func NewLoginService(users UserStore, passwords *PasswordPolicy, guard *LoginGuard, tokens *TokenService, mfa *MFAService, events SecurityEvents, log *logger.Logger) *LoginService {
+	return &LoginService{
+		users:     users,
+		passwords: passwords,
+		guard:     guard,
+		tokens:    tokens,
+		mfa:       mfa,
+		events:    events,
+		logger:    log,
+	}
+}
+
+// Login checks the credentials and returns a token pair, or an MFA token if
+// the user has two-factor authentication. It returns ErrInvalidCredentials
+// for a wrong email or password, and a *ThrottledError while the account or
+// IP is throttled or locked.
+func (s *LoginService) Login(ctx context.Context, email, password, ip string) (*LoginResult, error) {
+	if err := s.guard.Check(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	creds, err := s.users.GetCredentials(ctx, email)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	if creds == nil {
+		// Spend the same bcrypt time as for a real account, so response
+		// times don't reveal which emails are registered
+		bcrypt.CompareHashAndPassword(s.dummy(), []byte(password))
+		return nil, s.fail(ctx, email, "", ip, "password")
+	}
+
+	if !CheckPassword(creds.PasswordHash, password) {
+		return nil, s.fail(ctx, email, creds.UserID, ip, "password")
+	}
+
+	if s.mfa != nil {
+		enabled, err := s.mfa.Enabled(ctx, creds.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			// Failures are only reset once the second factor is verified too
+			token, err := s.mfa.newChallenge(ctx, creds)
+			if err != nil {
+				return nil, err
+			}
+			return &LoginResult{MFARequired: true, MFAToken: token}, nil
+		}
+	}
+
+	return s.succeed(ctx, creds, []string{middleware.AMRPassword})
+}
+
+// VerifyMFA completes a login that returned an MFA token, with a TOTP code
+// or a recovery code. Wrong codes count as failed logins.
+func (s *LoginService) VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode, ip string) (*LoginResult, error) {
+	if s.mfa == nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	creds, err := s.mfa.challenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Check(ctx, creds.Email, ip); err != nil {
+		return nil, err
+	}
+
+	method, err := s.mfa.Verify(ctx, creds.UserID, code, recoveryCode)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if ferr := s.fail(ctx, creds.Email, creds.UserID, ip, "totp"); !errors.Is(ferr, ErrInvalidCredentials) {
+			return nil, ferr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := s.mfa.completeChallenge(ctx, mfaToken); err != nil || !ok {
+		if err == nil {
+			err = ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	return s.succeed(ctx, creds, []string{middleware.AMRPassword, method})
+}
+
+// StepUp verifies a second factor for an authenticated session and returns
+// rotated tokens that pass step-up checks. Wrong codes count as failed logins,
+// so codes cannot be brute-forced with a stolen access token.
+func (s *LoginService) StepUp(ctx context.Context, refreshToken string, access *middleware.Claims, code, recoveryCode, ip string) (*TokenPair, error) {
+	if s.mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := s.guard.Check(ctx, access.Email, ip); err != nil {
+		return nil, err
+	}
+
+	method, err := s.mfa.Verify(ctx, access.UserID, code, recoveryCode)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if ferr := s.fail(ctx, access.Email, access.UserID, ip, "totp"); !errors.Is(ferr, ErrInvalidCredentials) {
+			return nil, ferr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokens.StepUp(ctx, refreshToken, access, method)
+}
+
+// succeed resets the account's failures and issues tokens for the login
+func (s *LoginService) succeed(ctx context.Context, creds *Credentials, amr []string) (*LoginResult, error) {
+	if err := s.guard.RecordSuccess(ctx, creds.Email); err != nil {
+		s.logger.Errorf("Failed to reset login failures: %v", err)
+	}
+
+	pair, err := s.tokens.IssueTokensWithAMR(ctx, creds.UserID, creds.Email, creds.Roles, amr)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: pair}, nil
+}
+
+// fail records a failed login and emits the security events for it.
+// userID is empty for unknown emails; factor is the factor that failed.
+func (s *LoginService) fail(ctx context.Context, email, userID, ip, factor string) error {
+	result, err := s.guard.RecordFailure(ctx, email, ip)
+	if err != nil {
+		return err
+	}
+
+	// Events for unknown emails are keyed by the email, so attacks on
+	// nonexistent accounts can still be audited
+	aggregateID := userID
+	if aggregateID == "" {
+		aggregateID = email
+	}
+
+	s.emit(ctx, EventLoginFailed, aggregateID, map[string]interface{}{
+		"user_id":          userID,
+		"email":            email,
+		"ip":               ip,
+		"factor":           factor,
+		"account_failures": result.AccountFailures,
+		"ip_failures":      result.IPFailures,
+	})
+
+	if result.Locked {
+		s.logger.Infof("Account %s locked for %s after %d failed logins", aggregateID, result.RetryAfter, result.AccountFailures)
+		s.emit(ctx, EventUserLocked, aggregateID, map[string]interface{}{
+			"user_id":        userID,
+			"email":          email,
+			"ip":             ip,
+			"failures":       result.AccountFailures,
+			"locked_seconds": int64(result.RetryAfter.Seconds()),
+		})
+	}
+
+	return ErrInvalidCredentials
+}
+
+// emit records a security event. A failure is only logged: losing an audit
+// event must not turn a rejected login into a server error.
+func (s *LoginService) emit(ctx context.Context, eventType, aggregateID string, payload map[string]interface{}) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Emit(ctx, eventType, aggregateID, payload); err != nil {
+		s.logger.Errorf("Failed to emit %s event: %v", eventType, err)
+	}
+}
+
+// dummy returns a hash at the policy's cost to compare unknown users against
+func (s *LoginService) dummy() []byte {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("mercuria-dummy-password"), s.passwords.cost())
+	})
+	return s.dummyHash
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+type fakeUserStore map[string]*Credentials
+
+func (s fakeUserStore) GetCredentials(ctx context.Context, email string) (*Credentials, error) {
+	if creds, ok := s[email]; ok {
+		return creds, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+type fakeSecurityEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *fakeSecurityEvents) Emit(ctx context.Context, eventType, aggregateID string, payload map[string]interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, eventType+":"+aggregateID)
+	return nil
+}
+
+func (e *fakeSecurityEvents) count(eventType string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, event := range e.events {
+		if strings.HasPrefix(event, eventType+":") {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestLoginMux serves the login endpoint for one user, alice@example.com
+// with password Correct-Horse-9
+func newTestLoginMux(t *testing.T, cfg config.LockoutConfig) (*http.ServeMux, *fakeSecurityEvents) {
+	t.Helper()
+
+	tokens, rdb := newTestTokenService(t)
+	log := logger.New("test")
+	passwords := NewPasswordPolicy(testPasswordConfig)
+
+	hash, err := passwords.Hash("Correct-Horse-9", "alice@example.com")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	users := fakeUserStore{
+		"alice@example.com": {UserID: "user-alice", Email: "alice@example.com", PasswordHash: hash},
+	}
+
+	events := &fakeSecurityEvents{}
+	login := NewLoginService(users, passwords, NewLoginGuard(rdb, cfg), tokens, nil, events, log)
+
+	mux := http.NewServeMux()
+	NewHandler(tokens, login, nil, log).RegisterRoutes(mux, func(next http.Handler) http.Handler { return next })
+	return mux, events
+}
+
+func doLogin(mux *http.ServeMux, email, password, ip string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(loginRequest{Email: email, Password: password})
+	req := httptest.NewRequest("POST", "/api/v1/login", bytes.NewReader(body))
+	req.RemoteAddr = ip + ":12345"
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLoginLockout(t *testing.T) {
+	mux, events := newTestLoginMux(t, config.LockoutConfig{
+		FailureWindow:   time.Minute,
+		MaxFailures:     3,
+		LockoutDuration: time.Minute,
+		DelayAfter:      10, // No delays before the lockout
+		MaxIPFailures:   100,
+	})
+
+	if rr := doLogin(mux, "alice@example.com", "Correct-Horse-9", "10.0.0.1"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d: %s", rr.Code, rr.Body)
+	}
+
+	for i := 0; i < 3; i++ {
+		if rr := doLogin(mux, "alice@example.com", "wrong", "10.0.0.1"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401 for failure %d, got %d", i+1, rr.Code)
+		}
+	}
+
+	// Locked, even with the right password and from another IP
+	rr := doLogin(mux, "alice@example.com", "Correct-Horse-9", "10.0.0.2")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected locked account to get 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	if n := events.count(EventLoginFailed); n != 3 {
+		t.Errorf("Expected 3 %s events, got %d", EventLoginFailed, n)
+	}
+	if n := events.count(EventUserLocked); n != 1 {
+		t.Errorf("Expected 1 %s event, got %d", EventUserLocked, n)
+	}
+}
+
+func TestLoginProgressiveDelay(t *testing.T) {
+	mux, _ := newTestLoginMux(t, config.LockoutConfig{
+		FailureWindow:   time.Minute,
+		MaxFailures:     10,
+		LockoutDuration: time.Minute,
+		DelayAfter:      2,
+		DelayBase:       time.Minute,
+		DelayMax:        time.Hour,
+		MaxIPFailures:   100,
+	})
+
+	doLogin(mux, "bob@example.com", "wrong", "10.0.0.1")
+	doLogin(mux, "bob@example.com", "wrong", "10.0.0.1")
+
+	// Unknown emails are throttled like real accounts
+	rr := doLogin(mux, "bob@example.com", "wrong", "10.0.0.1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected throttled login to get 429, got %d", rr.Code)
+	}
+
+	// Other accounts are unaffected
+	if rr := doLogin(mux, "alice@example.com", "Correct-Horse-9", "10.0.0.1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected other account to log in, got %d", rr.Code)
+	}
+}
+
+func TestLoginIPLimit(t *testing.T) {
+	mux, _ := newTestLoginMux(t, config.LockoutConfig{
+		FailureWindow:   time.Minute,
+		MaxFailures:     10,
+		LockoutDuration: time.Minute,
+		DelayAfter:      10,
+		MaxIPFailures:   3,
+	})
+
+	// Spraying one password across accounts trips the IP limit
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		doLogin(mux, email, "Password123", "10.0.0.9")
+	}
+
+	if rr := doLogin(mux, "alice@example.com", "Correct-Horse-9", "10.0.0.9"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected IP over its limit to get 429, got %d", rr.Code)
+	}
+	if rr := doLogin(mux, "alice@example.com", "Correct-Horse-9", "10.0.0.1"); rr.Code != http.StatusOK {
+		t.Errorf("Expected other IP to log in, got %d", rr.Code)
+	}
+}
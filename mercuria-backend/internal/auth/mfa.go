@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+)
+
+var (
+	ErrMFANotEnrolled     = errors.New("two-factor authentication not enrolled")
+	ErrMFAAlreadyEnrolled = errors.New("two-factor authentication already enrolled")
+	ErrNoPendingMFA       = errors.New("no pending two-factor enrolment")
+	ErrInvalidMFACode     = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken    = errors.New("invalid or expired MFA token")
+)
+
+// TOTPEnrollment is a user's confirmed TOTP factor
+type TOTPEnrollment struct {
+	Secret        string
+	RecoveryCodes []string // SHA-256 hashes of the unused recovery codes
+	EnrolledAt    time.Time
+}
+
+// MFAStore persists TOTP enrolments. GetTOTP returns ErrMFANotEnrolled for
+// users without one; UseRecoveryCode removes a recovery code hash and reports
+// whether it was present, atomically so each code works once.
+// NOTE: implementations should encrypt the secret at rest.
+type MFAStore interface {
+	GetTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	SaveTOTP(ctx context.Context, userID string, enrollment *TOTPEnrollment) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+}
+
+// PendingTOTP is a generated secret awaiting its first code
+type PendingTOTP struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// markStepScript accepts a TOTP time step only if it is newer than the last
+// accepted one, so an observed code cannot be replayed within its window
+var markStepScript = goredis.NewScript(`
+local last = tonumber(redis.call('GET', KEYS[1]) or '-1')
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// MFAService enrols and verifies TOTP second factors. Pending enrolments,
+// login challenges and replay markers live in Redis; confirmed enrolments
+// in the MFAStore.
+type MFAService struct {
+	rdb    *redis.Client
+	store  MFAStore
+	cfg    config.MFAConfig
+	events SecurityEvents
+	logger *logger.Logger
+}
+
+func NewMFAService(rdb *redis.Client, store MFAStore, cfg config.MFAConfig, events SecurityEvents, log *logger.Logger) *MFAService {
+	return &MFAService{
+		rdb:    rdb,
+		store:  store,
+		cfg:    cfg,
+		events: events,
+		logger: log,
+	}
+}
+
+func (s *MFAService) pendingKey(userID string) string {
+	return s.rdb.Keys().Key("mfa", "pending", redis.Tag(userID))
+}
+
+func (s *MFAService) lastStepKey(userID string) string {
+	return s.rdb.Keys().Key("mfa", "last_step", redis.Tag(userID))
+}
+
+func (s *MFAService) challengeKey(token string) string {
+	return s.rdb.Keys().Key("mfa", "challenge", redis.Tag(token))
+}
+
+// Enabled reports whether the user has a confirmed TOTP factor
+func (s *MFAService) Enabled(ctx context.Context, userID string) (bool, error) {
+	_, err := s.store.GetTOTP(ctx, userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+	return true, nil
+}
+
+// BeginEnrollment generates a TOTP secret for the user to add to their
+// authenticator app. It takes effect once confirmed with a valid code.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID, email string) (*PendingTOTP, error) {
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.rdb.Set(ctx, s.pendingKey(userID), secret, s.cfg.EnrollmentTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store pending TOTP secret: %w", err)
+	}
+
+	return &PendingTOTP{Secret: secret, URI: TOTPURI(s.cfg.Issuer, email, secret)}, nil
+}
+
+// ConfirmEnrollment enables the pending secret if code is valid, and returns
+// the recovery codes. They are shown once; only their hashes are kept.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	secret, err := s.rdb.Get(ctx, s.pendingKey(userID)).Result()
+	if err == goredis.Nil {
+		return nil, ErrNoPendingMFA
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending TOTP secret: %w", err)
+	}
+
+	if err := s.checkTOTP(ctx, userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes(s.cfg.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment := &TOTPEnrollment{Secret: secret, RecoveryCodes: hashes, EnrolledAt: time.Now()}
+	if err := s.store.SaveTOTP(ctx, userID, enrollment); err != nil {
+		return nil, fmt.Errorf("failed to save TOTP enrolment: %w", err)
+	}
+	s.rdb.Del(ctx, s.pendingKey(userID))
+
+	if s.events != nil {
+		payload := map[string]interface{}{"user_id": userID, "method": "totp"}
+		if err := s.events.Emit(ctx, EventMFAEnabled, userID, payload); err != nil {
+			s.logger.Errorf("Failed to emit %s event: %v", EventMFAEnabled, err)
+		}
+	}
+
+	return codes, nil
+}
+
+// Verify checks recoveryCode if given, otherwise the TOTP code, and returns
+// the AMR value of the factor used
+func (s *MFAService) Verify(ctx context.Context, userID, code, recoveryCode string) (string, error) {
+	enrollment, err := s.store.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+
+	if recoveryCode != "" {
+		used, err := s.store.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode))
+		if err != nil {
+			return "", fmt.Errorf("failed to use recovery code: %w", err)
+		}
+		if !used {
+			return "", ErrInvalidMFACode
+		}
+		s.logger.Infof("Recovery code used by user %s", userID)
+		return middleware.AMRRecoveryCode, nil
+	}
+
+	if err := s.checkTOTP(ctx, userID, enrollment.Secret, code); err != nil {
+		return "", err
+	}
+	return middleware.AMROTP, nil
+}
+
+// checkTOTP validates code and marks its time step as used
+func (s *MFAService) checkTOTP(ctx context.Context, userID, secret, code string) error {
+	step, ok := ValidateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// Steps stay valid for totpSkew periods either side of now
+	ttl := (2*totpSkew + 1) * totpPeriod
+	fresh, err := markStepScript.Run(ctx, s.rdb, []string{s.lastStepKey(userID)}, step, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	if fresh == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// newChallenge stores a password-verified login awaiting its second factor
+// and returns the token the client exchanges with the code
+func (s *MFAService) newChallenge(ctx context.Context, creds *Credentials) (string, error) {
+	token := middleware.NewTokenID()
+	key := s.challengeKey(token)
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", creds.UserID, "email", creds.Email, "roles", strings.Join(creds.Roles, " "))
+		pipe.Expire(ctx, key, s.cfg.ChallengeTTL)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store MFA challenge: %w", err)
+	}
+	return token, nil
+}
+
+// challenge returns the login awaiting a second factor under token
+func (s *MFAService) challenge(ctx context.Context, token string) (*Credentials, error) {
+	f, err := s.rdb.HGetAll(ctx, s.challengeKey(token)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MFA challenge: %w", err)
+	}
+	if f["user_id"] == "" {
+		return nil, ErrInvalidMFAToken
+	}
+	return &Credentials{UserID: f["user_id"], Email: f["email"], Roles: strings.Fields(f["roles"])}, nil
+}
+
+// completeChallenge deletes a challenge, reporting false if another request
+// completed it first
+func (s *MFAService) completeChallenge(ctx context.Context, token string) (bool, error) {
+	n, err := s.rdb.Del(ctx, s.challengeKey(token)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete MFA challenge: %w", err)
+	}
+	return n == 1, nil
+}
+
+// generateRecoveryCodes returns n codes formatted as xxxxx-xxxxx, and their hashes
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case and separators.
+// Codes carry 50 random bits, so a fast hash is enough.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
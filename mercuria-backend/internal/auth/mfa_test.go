@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+)
+
+type fakeMFAStore struct {
+	mu          sync.Mutex
+	enrollments map[string]*TOTPEnrollment
+}
+
+func (s *fakeMFAStore) GetTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.enrollments[userID]; ok {
+		return e, nil
+	}
+	return nil, ErrMFANotEnrolled
+}
+
+func (s *fakeMFAStore) SaveTOTP(ctx context.Context, userID string, enrollment *TOTPEnrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enrollments[userID] = enrollment
+	return nil
+}
+
+func (s *fakeMFAStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.enrollments[userID]
+	if !ok {
+		return false, nil
+	}
+	i := slices.Index(e.RecoveryCodes, codeHash)
+	if i < 0 {
+		return false, nil
+	}
+	e.RecoveryCodes = slices.Delete(e.RecoveryCodes, i, i+1)
+	return true, nil
+}
+
+func parseTestAccessToken(t *testing.T, token string) *middleware.Claims {
+	t.Helper()
+	claims := &middleware.Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testJWTConfig.Secret), nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	return claims
+}
+
+func TestMFAEnrollLoginAndStepUp(t *testing.T) {
+	tokens, rdb := newTestTokenService(t)
+	log := logger.New("test")
+	passwords := NewPasswordPolicy(testPasswordConfig)
+
+	hash, err := passwords.Hash("Correct-Horse-9", "carol@example.com")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	users := fakeUserStore{
+		"carol@example.com": {UserID: "user-carol", Email: "carol@example.com", PasswordHash: hash},
+	}
+
+	events := &fakeSecurityEvents{}
+	mfaCfg := config.MFAConfig{Issuer: "Mercuria", ChallengeTTL: time.Minute, EnrollmentTTL: time.Minute, RecoveryCodes: 3}
+	mfa := NewMFAService(rdb, &fakeMFAStore{enrollments: map[string]*TOTPEnrollment{}}, mfaCfg, events, log)
+	guard := NewLoginGuard(rdb, config.LockoutConfig{
+		FailureWindow: time.Minute, MaxFailures: 10, LockoutDuration: time.Minute, DelayAfter: 10, MaxIPFailures: 100,
+	})
+	login := NewLoginService(users, passwords, guard, tokens, mfa, events, log)
+
+	requireAuth, err := middleware.JWTAuthFromConfig(testJWTConfig, rdb)
+	if err != nil {
+		t.Fatalf("JWTAuthFromConfig failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewHandler(tokens, login, mfa, log).RegisterRoutes(mux, requireAuth)
+	mux.Handle("POST /api/v1/withdrawals", requireAuth(middleware.RequireStepUp(config.StepUpConfig{MaxAge: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))))
+
+	do := func(path, accessToken string, body, out interface{}) int {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(body)
+		req := httptest.NewRequest("POST", path, &buf)
+		req.RemoteAddr = "10.0.0.1:12345"
+		if accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if out != nil {
+			json.Unmarshal(rr.Body.Bytes(), out)
+		}
+		return rr.Code
+	}
+
+	// Password-only sessions cannot withdraw
+	var first LoginResult
+	if code := do("/api/v1/login", "", loginRequest{Email: "carol@example.com", Password: "Correct-Horse-9"}, &first); code != http.StatusOK || first.TokenPair == nil {
+		t.Fatalf("Expected login to succeed, got %d", code)
+	}
+	if claims := parseTestAccessToken(t, first.AccessToken); claims.ACR != middleware.ACRSingleFactor {
+		t.Errorf("Expected acr %s, got %s", middleware.ACRSingleFactor, claims.ACR)
+	}
+	if code := do("/api/v1/withdrawals", first.AccessToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected withdrawal without step-up to get 401, got %d", code)
+	}
+
+	// Enrol
+	var pending PendingTOTP
+	if code := do("/api/v1/mfa/totp/enroll", first.AccessToken, nil, &pending); code != http.StatusOK {
+		t.Fatalf("Expected enrolment to start, got %d", code)
+	}
+	now := time.Now()
+	code, _ := TOTPCode(pending.Secret, now)
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if status := do("/api/v1/mfa/totp/confirm", first.AccessToken, mfaCodeRequest{Code: code}, &confirmed); status != http.StatusOK {
+		t.Fatalf("Expected enrolment to be confirmed, got %d", status)
+	}
+	if len(confirmed.RecoveryCodes) != 3 {
+		t.Fatalf("Expected 3 recovery codes, got %v", confirmed.RecoveryCodes)
+	}
+	if n := events.count(EventMFAEnabled); n != 1 {
+		t.Errorf("Expected 1 %s event, got %d", EventMFAEnabled, n)
+	}
+
+	// Step up the existing session with the next code; the confirmed one is spent
+	next, _ := TOTPCode(pending.Secret, now.Add(totpPeriod))
+	if status := do("/api/v1/mfa/step-up", first.AccessToken, stepUpRequest{RefreshToken: first.RefreshToken, Code: code}, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected replayed code to be rejected, got %d", status)
+	}
+	var stepped TokenPair
+	if status := do("/api/v1/mfa/step-up", first.AccessToken, stepUpRequest{RefreshToken: first.RefreshToken, Code: next}, &stepped); status != http.StatusOK {
+		t.Fatalf("Expected step-up to succeed, got %d", status)
+	}
+	if status := do("/api/v1/withdrawals", stepped.AccessToken, nil, nil); status != http.StatusOK {
+		t.Errorf("Expected withdrawal after step-up to succeed, got %d", status)
+	}
+
+	// Refreshing keeps the second factor and its auth_time
+	refreshed, err := tokens.Refresh(context.Background(), stepped.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if claims := parseTestAccessToken(t, refreshed.AccessToken); claims.ACR != middleware.ACRMultiFactor || !slices.Contains(claims.AMR, middleware.AMROTP) {
+		t.Errorf("Expected refreshed token to keep MFA, got acr %s amr %v", claims.ACR, claims.AMR)
+	}
+
+	// Logins now need the second factor
+	var challenge LoginResult
+	do("/api/v1/login", "", loginRequest{Email: "carol@example.com", Password: "Correct-Horse-9"}, &challenge)
+	if !challenge.MFARequired || challenge.MFAToken == "" || challenge.TokenPair != nil {
+		t.Fatalf("Expected MFA challenge, got %+v", challenge)
+	}
+
+	if status := do("/api/v1/login/mfa", "", mfaLoginRequest{MFAToken: challenge.MFAToken, Code: "000000"}, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected wrong code to get 401, got %d", status)
+	}
+	// One for the replayed step-up code, one for the wrong code
+	if n := events.count(EventLoginFailed); n != 2 {
+		t.Errorf("Expected wrong codes to emit %s, got %d events", EventLoginFailed, n)
+	}
+
+	recovery := confirmed.RecoveryCodes[0]
+	var second LoginResult
+	if status := do("/api/v1/login/mfa", "", mfaLoginRequest{MFAToken: challenge.MFAToken, RecoveryCode: recovery}, &second); status != http.StatusOK || second.TokenPair == nil {
+		t.Fatalf("Expected recovery code login to succeed, got %d", status)
+	}
+	claims := parseTestAccessToken(t, second.AccessToken)
+	if claims.ACR != middleware.ACRMultiFactor || !slices.Contains(claims.AMR, middleware.AMRRecoveryCode) {
+		t.Errorf("Expected recovery code login to be multi-factor, got acr %s amr %v", claims.ACR, claims.AMR)
+	}
+
+	// The MFA token and the recovery code are single-use
+	if status := do("/api/v1/login/mfa", "", mfaLoginRequest{MFAToken: challenge.MFAToken, RecoveryCode: confirmed.RecoveryCodes[1]}, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected used MFA token to be rejected, got %d", status)
+	}
+	do("/api/v1/login", "", loginRequest{Email: "carol@example.com", Password: "Correct-Horse-9"}, &challenge)
+	if status := do("/api/v1/login/mfa", "", mfaLoginRequest{MFAToken: challenge.MFAToken, RecoveryCode: recovery}, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected used recovery code to be rejected, got %d", status)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords would be
+// silently truncated, so they are rejected instead
+const maxPasswordBytes = 72
+
+// PolicyError lists every rule a password breaks, so clients can show them all at once
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet policy: " + strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy validates and hashes passwords
+type PasswordPolicy struct {
+	cfg config.PasswordConfig
+}
+
+func NewPasswordPolicy(cfg config.PasswordConfig) *PasswordPolicy {
+	return &PasswordPolicy{cfg: cfg}
+}
+
+// Validate checks a new password against the policy. email is the account's
+// email; passwords containing its local part are rejected.
+func (p *PasswordPolicy) Validate(password, email string) error {
+	var violations []string
+
+	if len([]rune(password)) < p.cfg.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if local, _, _ := strings.Cut(strings.ToLower(email), "@"); len(local) >= 3 &&
+		strings.Contains(strings.ToLower(password), local) {
+		violations = append(violations, "must not contain your email address")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Hash validates a new password and hashes it with bcrypt
+func (p *PasswordPolicy) Hash(password, email string) (string, error) {
+	if err := p.Validate(password, email); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), p.cost())
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// cost is the configured bcrypt cost, never below bcrypt's default
+func (p *PasswordPolicy) cost() int {
+	if p.cfg.BcryptCost < bcrypt.DefaultCost {
+		return bcrypt.DefaultCost
+	}
+	return p.cfg.BcryptCost
+}
+
+// CheckPassword reports whether password matches a bcrypt hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+)
+
+var testPasswordConfig = config.PasswordConfig{
+	MinLength:    10,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	BcryptCost:   4, // Raised to bcrypt.DefaultCost
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := NewPasswordPolicy(testPasswordConfig)
+
+	tests := []struct {
+		name       string
+		password   string
+		violations int
+	}{
+		{"valid", "Correct-Horse-9", 0},
+		{"too short", "Short1a", 1},
+		{"no uppercase", "lowercase-only-1", 1},
+		{"no digit or uppercase", "lowercase-only", 2},
+		{"contains email", "Alice-Secret-99", 1},
+		{"too long", "Aa1" + strings.Repeat("x", 70), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "alice@example.com")
+
+			if tt.violations == 0 {
+				if err != nil {
+					t.Errorf("Expected valid password, got %v", err)
+				}
+				return
+			}
+
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Expected PolicyError, got %v", err)
+			}
+			if len(policyErr.Violations) != tt.violations {
+				t.Errorf("Expected %d violations, got %v", tt.violations, policyErr.Violations)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyHash(t *testing.T) {
+	policy := NewPasswordPolicy(testPasswordConfig)
+
+	hash, err := policy.Hash("Correct-Horse-9", "alice@example.com")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !CheckPassword(hash, "Correct-Horse-9") {
+		t.Error("Expected password to match its hash")
+	}
+	if CheckPassword(hash, "Wrong-Horse-9") {
+		t.Error("Expected wrong password not to match")
+	}
+
+	if _, err := policy.Hash("weak", "alice@example.com"); err == nil {
+		t.Error("Expected weak password to be rejected")
+	}
+}
@@ -0,0 +1,376 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/jwks"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// refreshClaims are the claims of a refresh token. FamilyID ties together
+// every token rotated from the same login.
+type refreshClaims struct {
+	FamilyID string `json:"fid"`
+	TokenUse string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// rotateScript swaps the family's current refresh jti for a new one. If the
+// presented jti is not the current one, an old token is being replayed: the
+// whole family is revoked, since we cannot tell the thief from the user.
+// Returns {status, user_id, email, access_jti, roles, amr, auth_time}:
+// 1 rotated, 0 reused, -1 unknown.
+var rotateScript = goredis.NewScript(`
+local f = redis.call('HMGET', KEYS[1], 'current_jti', 'user_id', 'email', 'access_jti', 'roles', 'amr', 'auth_time')
+if not f[1] then
+	return {-1}
+end
+if f[1] ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return {0, f[2], f[3], f[4], f[5], f[6], f[7]}
+end
+redis.call('HSET', KEYS[1], 'current_jti', ARGV[2], 'access_jti', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {1, f[2], f[3], f[4], f[5], f[6], f[7]}
+`)
+
+// stepUpScript records a second-factor verification on an existing family
+var stepUpScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'amr', ARGV[1], 'auth_time', ARGV[2])
+return 1
+`)
+
+// TokenService issues access tokens and server-side tracked refresh tokens.
+// Each login starts a refresh token family in Redis; every refresh rotates the
+// token, and logout revokes the family plus its current access token.
+type TokenService struct {
+	rdb    *redis.Client
+	cfg    config.JWTConfig
+	keys   *jwks.KeySet // nil signs with the HS256 shared secret
+	logger *logger.Logger
+}
+
+func NewTokenService(rdb *redis.Client, cfg config.JWTConfig, keys *jwks.KeySet, log *logger.Logger) *TokenService {
+	return &TokenService{
+		rdb:    rdb,
+		cfg:    cfg,
+		keys:   keys,
+		logger: log,
+	}
+}
+
+func (s *TokenService) familyKey(familyID string) string {
+	return s.rdb.Keys().Key("refresh", "family", redis.Tag(familyID))
+}
+
+func (s *TokenService) userFamiliesKey(userID string) string {
+	return s.rdb.Keys().Key("refresh", "user", redis.Tag(userID))
+}
+
+// IssueTokens starts a new refresh token family after a password-only login.
+// Roles are fixed for the family's lifetime; role changes apply on next login
+// (or immediately, with LogoutAll).
+func (s *TokenService) IssueTokens(ctx context.Context, userID, email string, roles []string) (*TokenPair, error) {
+	return s.IssueTokensWithAMR(ctx, userID, email, roles, []string{middleware.AMRPassword})
+}
+
+// IssueTokensWithAMR starts a new refresh token family for a login that used
+// the given authentication methods. Refreshed tokens keep the methods and
+// their auth_time, so step-up checks still see how long ago they were verified.
+func (s *TokenService) IssueTokensWithAMR(ctx context.Context, userID, email string, roles, amr []string) (*TokenPair, error) {
+	if len(roles) == 0 {
+		roles = []string{middleware.RoleUser}
+	}
+
+	familyID := middleware.NewTokenID()
+	refreshJTI := middleware.NewTokenID()
+	authTime := time.Now()
+	access := newAccessClaims(userID, email, roles, s.cfg)
+	setAuthentication(&access, amr, authTime)
+
+	familyKey := s.familyKey(familyID)
+	userKey := s.userFamiliesKey(userID)
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, familyKey, "user_id", userID, "email", email, "current_jti", refreshJTI, "access_jti", access.ID,
+			"roles", strings.Join(roles, " "), "amr", strings.Join(amr, " "), "auth_time", authTime.Unix())
+		pipe.Expire(ctx, familyKey, s.cfg.RefreshTokenTTL)
+		pipe.SAdd(ctx, userKey, familyID)
+		pipe.Expire(ctx, userKey, s.cfg.RefreshTokenTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token family: %w", err)
+	}
+
+	return s.signPair(access, userID, familyID, refreshJTI)
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already
+// rotated revokes its family and returns ErrRefreshTokenReused.
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	newJTI := middleware.NewTokenID()
+	access := newAccessClaims("", "", nil, s.cfg)
+
+	res, err := rotateScript.Run(ctx, s.rdb, []string{s.familyKey(claims.FamilyID)},
+		claims.ID, newJTI, access.ID, s.cfg.RefreshTokenTTL.Milliseconds()).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	status, _ := res[0].(int64)
+	switch status {
+	case -1:
+		return nil, ErrInvalidRefreshToken
+	case 0:
+		s.logger.Warnf("Refresh token reuse detected for user %s, family %s revoked", claims.Subject, claims.FamilyID)
+		s.rdb.SRem(ctx, s.userFamiliesKey(claims.Subject), claims.FamilyID)
+		if accessJTI, ok := res[3].(string); ok {
+			if err := s.rdb.RevokeToken(ctx, accessJTI, s.cfg.AccessTokenTTL); err != nil {
+				s.logger.Errorf("Failed to revoke access token of reused family: %v", err)
+			}
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	userID, _ := res[1].(string)
+	email, _ := res[2].(string)
+	if userID != claims.Subject {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// The user's families set must live as long as the family just extended,
+	// or LogoutAll would miss sessions kept alive by refreshing. The set is in
+	// another cluster slot than the family, so this cannot be in rotateScript;
+	// re-adding the family also repairs a set that has already expired.
+	userKey := s.userFamiliesKey(userID)
+	_, err = s.rdb.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.SAdd(ctx, userKey, claims.FamilyID)
+		pipe.Expire(ctx, userKey, s.cfg.RefreshTokenTTL)
+		return nil
+	})
+	if err != nil {
+		s.logger.Errorf("Failed to extend refresh token families of user %s: %v", userID, err)
+	}
+
+	roles, _ := res[4].(string)
+	amr, _ := res[5].(string)
+	authTime, _ := res[6].(string)
+
+	access.UserID = userID
+	access.Email = email
+	access.Roles = strings.Fields(roles)
+	access.Scopes = middleware.ScopesForRoles(access.Roles)
+	if unix, err := strconv.ParseInt(authTime, 10, 64); err == nil {
+		setAuthentication(&access, strings.Fields(amr), time.Unix(unix, 0))
+	}
+
+	return s.signPair(access, userID, claims.FamilyID, newJTI)
+}
+
+// StepUp records a second-factor verification (method is an AMR value) on the
+// session of refreshToken and rotates it, returning tokens that satisfy
+// step-up checks. The caller must have verified the factor for access.UserID.
+func (s *TokenService) StepUp(ctx context.Context, refreshToken string, access *middleware.Claims, method string) (*TokenPair, error) {
+	claims, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if access == nil || claims.Subject != access.UserID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	amr := strings.Join([]string{middleware.AMRPassword, method}, " ")
+	ok, err := stepUpScript.Run(ctx, s.rdb, []string{s.familyKey(claims.FamilyID)}, amr, time.Now().Unix()).Int()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record step-up: %w", err)
+	}
+	if ok == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.Refresh(ctx, refreshToken)
+}
+
+// Logout revokes the family of the given refresh token, together with the
+// access token used to call logout
+func (s *TokenService) Logout(ctx context.Context, refreshToken string, access *middleware.Claims) error {
+	claims, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	if access != nil && claims.Subject != access.UserID {
+		return ErrInvalidRefreshToken
+	}
+
+	if err := s.revokeFamily(ctx, claims.Subject, claims.FamilyID); err != nil {
+		return err
+	}
+
+	return s.revokeAccessToken(ctx, access)
+}
+
+// LogoutAll revokes every refresh token family of a user, logging out all devices
+func (s *TokenService) LogoutAll(ctx context.Context, userID string, access *middleware.Claims) error {
+	familyIDs, err := s.rdb.SMembers(ctx, s.userFamiliesKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list refresh token families: %w", err)
+	}
+
+	for _, familyID := range familyIDs {
+		if err := s.revokeFamily(ctx, userID, familyID); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Infof("Revoked %d sessions for user %s", len(familyIDs), userID)
+	return s.revokeAccessToken(ctx, access)
+}
+
+// revokeFamily deletes a family and denylists the last access token it issued
+func (s *TokenService) revokeFamily(ctx context.Context, userID, familyID string) error {
+	familyKey := s.familyKey(familyID)
+
+	accessJTI, err := s.rdb.HGet(ctx, familyKey, "access_jti").Result()
+	if err != nil && err != goredis.Nil {
+		return fmt.Errorf("failed to read refresh token family: %w", err)
+	}
+
+	if err := s.rdb.Del(ctx, familyKey).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	s.rdb.SRem(ctx, s.userFamiliesKey(userID), familyID)
+
+	if accessJTI != "" {
+		if err := s.rdb.RevokeToken(ctx, accessJTI, s.cfg.AccessTokenTTL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *TokenService) revokeAccessToken(ctx context.Context, access *middleware.Claims) error {
+	if access == nil || access.ID == "" || access.ExpiresAt == nil {
+		return nil
+	}
+	return s.rdb.RevokeToken(ctx, access.ID, time.Until(access.ExpiresAt.Time))
+}
+
+func newAccessClaims(userID, email string, roles []string, cfg config.JWTConfig) middleware.Claims {
+	claims := middleware.NewAccessClaims(userID, email, cfg)
+	claims.Roles = roles
+	claims.Scopes = middleware.ScopesForRoles(roles)
+	return claims
+}
+
+// setAuthentication sets the amr, acr and auth_time claims
+func setAuthentication(claims *middleware.Claims, amr []string, authTime time.Time) {
+	claims.AMR = amr
+	claims.ACR = middleware.ACRForAMR(amr)
+	claims.AuthTime = jwt.NewNumericDate(authTime)
+}
+
+func (s *TokenService) signPair(access middleware.Claims, userID, familyID, refreshJTI string) (*TokenPair, error) {
+	accessToken, err := s.sign(access)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	now := time.Now()
+	refresh := refreshClaims{
+		FamilyID: familyID,
+		TokenUse: "refresh",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        refreshJTI,
+			Subject:   userID,
+			Issuer:    s.cfg.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.RefreshTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if s.cfg.Audience != "" {
+		refresh.Audience = jwt.ClaimStrings{s.cfg.Audience}
+	}
+	refreshToken, err := s.sign(refresh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *TokenService) sign(claims jwt.Claims) (string, error) {
+	if s.keys != nil {
+		return s.keys.Sign(claims)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.Secret))
+}
+
+func (s *TokenService) parseRefreshToken(tokenString string) (*refreshClaims, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.Secret), nil
+	}
+	methods := []string{"HS256"}
+	if s.keys != nil {
+		keyfunc = s.keys.Keyfunc
+		methods = []string{s.keys.Algorithm()}
+	}
+
+	// Checked like access tokens, so tokens of another issuer or audience
+	// sharing the keys are not accepted
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods)}
+	if s.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.cfg.Issuer))
+	}
+	if s.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(s.cfg.Audience))
+	}
+
+	claims := &refreshClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyfunc, opts...)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+	if claims.TokenUse != "refresh" || claims.FamilyID == "" || claims.ID == "" || claims.Subject == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/middleware"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+)
+
+var testJWTConfig = config.JWTConfig{
+	Secret:          "test-secret",
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: 24 * time.Hour,
+	Algorithm:       "HS256",
+	Issuer:          "mercuria-auth",
+	Audience:        "mercuria",
+}
+
+func newTestTokenService(t *testing.T) (*TokenService, *redis.Client) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	cfg := config.RedisConfig{
+		Host:      "localhost",
+		Port:      "6379",
+		KeyPrefix: "mercuria:test",
+		Service:   "auth",
+	}
+
+	log := logger.New("test")
+	rdb, err := redis.Connect(cfg, log)
+	if err != nil {
+		t.Skip("Redis not available")
+	}
+	t.Cleanup(func() {
+		rdb.DeleteNamespace(context.Background(), false)
+		rdb.Close()
+	})
+
+	return NewTokenService(rdb, testJWTConfig, nil, log), rdb
+}
+
+func TestRefreshRotation(t *testing.T) {
+	tokens, _ := newTestTokenService(t)
+	ctx := context.Background()
+
+	first, err := tokens.IssueTokens(ctx, "user-123", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	second, err := tokens.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Expected refresh token to rotate")
+	}
+
+	// Replaying the rotated token revokes the family
+	if _, err := tokens.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// ... including the token the legitimate client holds
+	if _, err := tokens.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected family to be revoked, got %v", err)
+	}
+}
+
+func TestRefreshKeepsRoles(t *testing.T) {
+	tokens, _ := newTestTokenService(t)
+	ctx := context.Background()
+
+	pair, err := tokens.IssueTokens(ctx, "admin-1", "admin@example.com", []string{middleware.RoleAdmin})
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	pair, err = tokens.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(pair.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testJWTConfig.Secret), nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+
+	if !claims.HasRole(middleware.RoleAdmin) {
+		t.Errorf("Expected admin role after refresh, got %v", claims.Roles)
+	}
+	if !claims.HasScope(middleware.ScopeOutboxReplay) {
+		t.Errorf("Expected outbox replay scope after refresh, got %v", claims.Scopes)
+	}
+}
+
+func TestRefreshRejectsAccessToken(t *testing.T) {
+	tokens, _ := newTestTokenService(t)
+	ctx := context.Background()
+
+	pair, err := tokens.IssueTokens(ctx, "user-123", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	if _, err := tokens.Refresh(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected access token to be rejected, got %v", err)
+	}
+}
+
+func TestRefreshChecksIssuerAndAudience(t *testing.T) {
+	tokens, _ := newTestTokenService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		issuer   string
+		audience []string
+	}{
+		{"other issuer", "other-auth", []string{testJWTConfig.Audience}},
+		{"other audience", testJWTConfig.Issuer, []string{"other-service"}},
+		{"no audience", testJWTConfig.Issuer, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := tokens.IssueTokens(ctx, "user-123", "test@example.com", nil)
+			if err != nil {
+				t.Fatalf("IssueTokens failed: %v", err)
+			}
+
+			// Re-sign the live refresh token with only iss or aud changed
+			claims := &refreshClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(pair.RefreshToken, claims); err != nil {
+				t.Fatalf("Failed to parse refresh token: %v", err)
+			}
+			claims.Issuer = tt.issuer
+			claims.Audience = tt.audience
+			forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTConfig.Secret))
+			if err != nil {
+				t.Fatalf("Failed to sign token: %v", err)
+			}
+
+			if _, err := tokens.Refresh(ctx, forged); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+			}
+			// The genuine token is still unused
+			if _, err := tokens.Refresh(ctx, pair.RefreshToken); err != nil {
+				t.Errorf("Expected the genuine refresh token to work, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogoutAllRevokesAccessTokens(t *testing.T) {
+	tokens, rdb := newTestTokenService(t)
+	ctx := context.Background()
+	log := logger.New("test")
+
+	phone, err := tokens.IssueTokens(ctx, "user-456", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	laptop, err := tokens.IssueTokens(ctx, "user-456", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	requireAuth, err := middleware.JWTAuthFromConfig(testJWTConfig, rdb)
+	if err != nil {
+		t.Fatalf("JWTAuthFromConfig failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewHandler(tokens, nil, nil, log).RegisterRoutes(mux, requireAuth)
+	mux.Handle("GET /api/v1/me", requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	do := func(method, path, accessToken string, body interface{}) int {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := do("GET", "/api/v1/me", laptop.AccessToken, nil); code != http.StatusOK {
+		t.Fatalf("Expected laptop token to work, got %d", code)
+	}
+
+	if code := do("POST", "/api/v1/logout/all", phone.AccessToken, nil); code != http.StatusNoContent {
+		t.Fatalf("Expected logout all to succeed, got %d", code)
+	}
+
+	if code := do("GET", "/api/v1/me", laptop.AccessToken, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected laptop access token to be revoked, got %d", code)
+	}
+	if code := do("GET", "/api/v1/me", phone.AccessToken, nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected phone access token to be revoked, got %d", code)
+	}
+
+	body := refreshRequest{RefreshToken: laptop.RefreshToken}
+	if code := do("POST", "/api/v1/token/refresh", "", body); code != http.StatusUnauthorized {
+		t.Errorf("Expected laptop refresh token to be revoked, got %d", code)
+	}
+}
+
+func TestLogoutAllAfterRotationPastLoginTTL(t *testing.T) {
+	tokens, rdb := newTestTokenService(t)
+	ctx := context.Background()
+
+	pair, err := tokens.IssueTokens(ctx, "user-789", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	// Age the session to the end of its login TTL, then keep it alive
+	userKey := tokens.userFamiliesKey("user-789")
+	rdb.PExpire(ctx, userKey, time.Second)
+
+	pair, err = tokens.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if ttl := rdb.PTTL(ctx, userKey).Val(); ttl < time.Hour {
+		t.Errorf("Expected refresh to extend the families set, got TTL %v", ttl)
+	}
+
+	// Past the login TTL, the session must still be found by LogoutAll
+	time.Sleep(1100 * time.Millisecond)
+
+	if err := tokens.LogoutAll(ctx, "user-789", nil); err != nil {
+		t.Fatalf("LogoutAll failed: %v", err)
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected ErrInvalidRefreshToken after LogoutAll, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the RFC 6238 test key "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 Appendix B SHA-1 vectors, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("Expected code %s at %d, got %s", tt.code, tt.unix, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+
+	now := time.Now()
+	code, _ := TOTPCode(secret, now)
+
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Error("Expected current code to be valid")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(totpPeriod)); !ok {
+		t.Error("Expected code from the previous step to be accepted for clock drift")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(3*totpPeriod)); ok {
+		t.Error("Expected code from three steps ago to be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("Expected short code to be rejected")
+	}
+
+	uri := TOTPURI("Mercuria", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Mercuria:alice@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Unexpected otpauth URI %s", uri)
+	}
+}
@@ -0,0 +1,251 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+)
+
+// Code is a machine-readable error code. Clients switch on the code; the
+// message is for humans and may change.
+type Code string
+
+const (
+	CodeBadRequest        Code = "bad_request"
+	CodeValidation        Code = "validation_failed"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeNotFound          Code = "not_found"
+	CodeConflict          Code = "conflict"
+	CodeInsufficientFunds Code = "insufficient_funds"
+	CodeTooLarge          Code = "payload_too_large"
+	CodeUnsupportedMedia  Code = "unsupported_media_type"
+	CodeRateLimited       Code = "rate_limited"
+	CodeUnavailable       Code = "service_unavailable"
+	CodeTimeout           Code = "timeout"
+	CodeInternal          Code = "internal_error"
+)
+
+// statusByCode maps the standard codes to HTTP status codes
+var statusByCode = map[Code]int{
+	CodeBadRequest:        http.StatusBadRequest,
+	CodeValidation:        http.StatusUnprocessableEntity,
+	CodeUnauthorized:      http.StatusUnauthorized,
+	CodeForbidden:         http.StatusForbidden,
+	CodeNotFound:          http.StatusNotFound,
+	CodeConflict:          http.StatusConflict,
+	CodeInsufficientFunds: http.StatusUnprocessableEntity,
+	CodeTooLarge:          http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMedia:  http.StatusUnsupportedMediaType,
+	CodeRateLimited:       http.StatusTooManyRequests,
+	CodeUnavailable:       http.StatusServiceUnavailable,
+	CodeTimeout:           http.StatusGatewayTimeout,
+	CodeInternal:          http.StatusInternalServerError,
+}
+
+// defaultMessages are sent for errors without a message, such as the sentinels
+// returned as is
+var defaultMessages = map[Code]string{
+	CodeBadRequest:        "bad request",
+	CodeValidation:        "request validation failed",
+	CodeUnauthorized:      "unauthorized",
+	CodeForbidden:         "forbidden",
+	CodeNotFound:          "resource not found",
+	CodeConflict:          "conflict",
+	CodeInsufficientFunds: "insufficient funds",
+	CodeTooLarge:          "request body too large",
+	CodeUnsupportedMedia:  "unsupported media type",
+	CodeRateLimited:       "too many requests",
+	CodeUnavailable:       "service unavailable",
+	CodeTimeout:           "request timed out",
+	CodeInternal:          "internal server error",
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`   // JSON path of the field, e.g. "recipients[2].amount"
+	Code    string `json:"code"`    // e.g. "required", "invalid_format", "too_large"
+	Message string `json:"message"` // Human-readable
+}
+
+// Error is an error with an HTTP status, a code and a message that are safe
+// to show to clients. The wrapped cause is logged, never sent.
+type Error struct {
+	Status     int
+	Code       Code
+	Message    string
+	Fields     []FieldError
+	RetryAfter time.Duration // Sent as Retry-After when set
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches another *Error with the same code, so errors.Is(err,
+// apierror.ErrNotFound) works for any not-found error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Sentinels for errors.Is checks on the code. Returned as is, they are sent
+// with the status and default message of their code.
+var (
+	ErrNotFound          = New(CodeNotFound, defaultMessages[CodeNotFound])
+	ErrConflict          = New(CodeConflict, defaultMessages[CodeConflict])
+	ErrInsufficientFunds = New(CodeInsufficientFunds, defaultMessages[CodeInsufficientFunds])
+	ErrValidation        = New(CodeValidation, defaultMessages[CodeValidation])
+)
+
+// New creates an error with the status of a standard code, or 400 for
+// service-specific codes
+func New(code Code, message string) *Error {
+	status, ok := statusByCode[code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithStatus creates an error with an explicit status, for service-specific
+// codes such as "step_up_required"
+func WithStatus(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns a copy of e that wraps cause
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.Err = cause
+	return &c
+}
+
+func BadRequest(message string) *Error {
+	return New(CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(CodeForbidden, message)
+}
+
+// NotFound reports a missing resource, e.g. NotFound("wallet")
+func NotFound(resource string) *Error {
+	return New(CodeNotFound, resource+" not found")
+}
+
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+func InsufficientFunds(message string) *Error {
+	return New(CodeInsufficientFunds, message)
+}
+
+// Validation reports invalid request fields
+func Validation(fields ...FieldError) *Error {
+	e := New(CodeValidation, "request validation failed")
+	e.Fields = fields
+	return e
+}
+
+// RateLimited reports a throttled request, retryable after retryAfter
+func RateLimited(message string, retryAfter time.Duration) *Error {
+	e := New(CodeRateLimited, message)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+func Unavailable(message string) *Error {
+	return New(CodeUnavailable, message)
+}
+
+// Internal hides cause behind a generic message
+func Internal(cause error) *Error {
+	return New(CodeInternal, defaultMessages[CodeInternal]).Wrap(cause)
+}
+
+// From converts err to an *Error. Errors that are not an *Error, and not a
+// context deadline, become internal errors.
+func From(err error) *Error {
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(CodeTimeout, defaultMessages[CodeTimeout]).Wrap(err)
+	default:
+		return Internal(err)
+	}
+}
+
+// Response is the JSON body of every error response. error duplicates
+// message for clients written against the old {"error": "..."} body.
+type Response struct {
+	Error     string       `json:"error"`
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Write sends err as a JSON error response with the status of its code and
+// the request ID of r. An *Error without a status or message gets the ones
+// of its code. Any error other than an *Error is sent as a 500 without its
+// details.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err)
+
+	status := apiErr.Status
+	if status == 0 {
+		var ok bool
+		if status, ok = statusByCode[apiErr.Code]; !ok {
+			status = http.StatusInternalServerError
+		}
+	}
+	message := apiErr.Message
+	if message == "" {
+		var ok bool
+		if message, ok = defaultMessages[apiErr.Code]; !ok {
+			message = strings.ToLower(http.StatusText(status))
+		}
+	}
+
+	resp := Response{
+		Error:   message,
+		Code:    apiErr.Code,
+		Message: message,
+		Details: apiErr.Fields,
+	}
+	if r != nil {
+		resp.RequestID, _ = requestid.FromContext(r.Context())
+	}
+
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
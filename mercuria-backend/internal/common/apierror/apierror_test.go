@@ -0,0 +1,192 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   Code
+		expectedMsg    string
+	}{
+		{
+			name:           "not found",
+			err:            NotFound("wallet"),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   CodeNotFound,
+			expectedMsg:    "wallet not found",
+		},
+		{
+			name:           "insufficient funds",
+			err:            InsufficientFunds("balance too low"),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   CodeInsufficientFunds,
+			expectedMsg:    "balance too low",
+		},
+		{
+			name:           "conflict",
+			err:            Conflict("idempotency key reused"),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   CodeConflict,
+			expectedMsg:    "idempotency key reused",
+		},
+		{
+			name:           "wrapped api error",
+			err:            fmt.Errorf("failed to transfer: %w", Forbidden("not your wallet")),
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   CodeForbidden,
+			expectedMsg:    "not your wallet",
+		},
+		{
+			name:           "service-specific code",
+			err:            WithStatus(http.StatusUnauthorized, "step_up_required", "step-up required"),
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "step_up_required",
+			expectedMsg:    "step-up required",
+		},
+		{
+			name:           "deadline exceeded",
+			err:            fmt.Errorf("failed to query: %w", context.DeadlineExceeded),
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedCode:   CodeTimeout,
+			expectedMsg:    "request timed out",
+		},
+		{
+			name:           "wrapped sentinel",
+			err:            fmt.Errorf("load: %w", ErrNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   CodeNotFound,
+			expectedMsg:    "resource not found",
+		},
+		{
+			name:           "error without status or message",
+			err:            &Error{Code: CodeConflict},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   CodeConflict,
+			expectedMsg:    "conflict",
+		},
+		{
+			name:           "service-specific code without status or message",
+			err:            &Error{Code: "limit_exceeded"},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "limit_exceeded",
+			expectedMsg:    "internal server error",
+		},
+		{
+			name:           "unknown error is hidden",
+			err:            errors.New("pq: connection refused to 10.0.0.5"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeInternal,
+			expectedMsg:    "internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			rr := httptest.NewRecorder()
+
+			Write(rr, req, tt.err)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type application/json, got %q", ct)
+			}
+
+			var body Response
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if body.Code != tt.expectedCode {
+				t.Errorf("Expected code %s, got %s", tt.expectedCode, body.Code)
+			}
+			if body.Message != tt.expectedMsg {
+				t.Errorf("Expected message %q, got %q", tt.expectedMsg, body.Message)
+			}
+			if body.Error != body.Message {
+				t.Errorf("Expected error to match message, got %q", body.Error)
+			}
+		})
+	}
+}
+
+func TestWriteValidation(t *testing.T) {
+	req := httptest.NewRequest("POST", "/test", nil)
+	rr := httptest.NewRecorder()
+
+	Write(rr, req, Validation(
+		FieldError{Field: "amount", Code: "required", Message: "amount is required"},
+		FieldError{Field: "currency", Code: "invalid_format", Message: "currency must be an ISO 4217 code"},
+	))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", rr.Code)
+	}
+
+	var body Response
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if len(body.Details) != 2 {
+		t.Fatalf("Expected 2 field errors, got %d", len(body.Details))
+	}
+	if body.Details[1].Field != "currency" || body.Details[1].Code != "invalid_format" {
+		t.Errorf("Expected currency/invalid_format, got %s/%s", body.Details[1].Field, body.Details[1].Code)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req = req.WithContext(requestid.NewContext(req.Context(), "req-123"))
+	rr := httptest.NewRecorder()
+
+	Write(rr, req, RateLimited("rate limit exceeded", 1500*time.Millisecond))
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+
+	var body Response
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.RequestID != "req-123" {
+		t.Errorf("Expected request_id req-123, got %q", body.RequestID)
+	}
+}
+
+func TestErrorsIs(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("failed to get wallet: %w", NotFound("wallet").Wrap(cause))
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Expected errors.Is to match ErrNotFound")
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Error("Expected errors.Is not to match ErrConflict")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected errors.Is to match the wrapped cause")
+	}
+	if !strings.Contains(err.Error(), "not_found") {
+		t.Errorf("Expected error string to contain the code, got %q", err.Error())
+	}
+}
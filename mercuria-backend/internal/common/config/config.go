@@ -2,24 +2,42 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/money"
 )
 
 type Config struct {
-	Service  ServiceConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Kafka    KafkaConfig
-	JWT      JWTConfig
+	Service   ServiceConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Kafka     KafkaConfig
+	JWT       JWTConfig
+	RateLimit RateLimitConfig
+	CORS      CORSConfig
+	Password  PasswordConfig
+	Lockout   LockoutConfig
+	MFA       MFAConfig
+	StepUp    StepUpConfig
+	Tracing   TracingConfig
+	Health    HealthConfig
+	AccessLog AccessLogConfig
+	FX        FXConfig
 }
 
 type ServiceConfig struct {
 	Name        string
 	Port        string
 	Environment string // dev, staging, production
+
+	// TrustedProxies are the load balancers and proxies whose X-Forwarded-For
+	// header is believed when resolving the client IP
+	TrustedProxies []netip.Prefix
 }
 
 type DatabaseConfig struct {
@@ -38,8 +56,33 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+
+	Mode             string   // standalone, sentinel, cluster
+	Addrs            []string // Sentinel or cluster seed addresses (host:port)
+	MasterName       string   // Sentinel master name
+	SentinelPassword string
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	TLSEnabled bool
+	TLSCACert  string // Path to CA certificate; system roots are used if empty
+
+	// Keys are namespaced as <KeyPrefix>:<Service>:... so environments and
+	// services can share one Redis. Load derives both from ServiceConfig.
+	KeyPrefix string
+	Service   string
 }
 
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type KafkaConfig struct {
 	Brokers []string
 	GroupID string
@@ -49,6 +92,111 @@ type JWTConfig struct {
 	Secret           string
 	AccessTokenTTL   time.Duration
 	RefreshTokenTTL  time.Duration
+
+	Algorithm      string        // HS256 (shared secret), RS256 or EdDSA
+	Issuer         string        // Expected iss claim, checked if set
+	Audience       string        // Expected aud claim, checked if set
+	SigningKeysDir string        // Auth service only: PEM private keys for RS256/EdDSA
+	JWKSURL        string        // URL or file path of the auth service's JWK Set
+	JWKSCacheTTL   time.Duration // How long fetched keys are trusted before a refresh
+}
+
+// CORSConfig is the browser cross-origin policy. AllowedOrigins entries are
+// exact origins or patterns with one "*", e.g. "https://*.mercuria.app".
+// A bare "*" allows any origin and cannot be combined with AllowCredentials.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// PasswordConfig is the password policy enforced by the auth service
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	BcryptCost    int
+}
+
+// LockoutConfig controls login throttling. After DelayAfter failed logins an
+// account must wait DelayBase, doubling per failure up to DelayMax; after
+// MaxFailures it is locked for LockoutDuration. Failures per IP are capped
+// separately, against password spraying across accounts.
+type LockoutConfig struct {
+	FailureWindow   time.Duration
+	MaxFailures     int
+	LockoutDuration time.Duration
+	DelayAfter      int
+	DelayBase       time.Duration
+	DelayMax        time.Duration
+	MaxIPFailures   int
+}
+
+// MFAConfig configures TOTP two-factor authentication in the auth service
+type MFAConfig struct {
+	Issuer        string        // Shown in authenticator apps
+	ChallengeTTL  time.Duration // Time to enter the code after the password
+	EnrollmentTTL time.Duration // Time to confirm a new TOTP secret
+	RecoveryCodes int           // Recovery codes issued on enrolment
+}
+
+// StepUpConfig controls which operations need a recent second-factor
+// verification. TransferThresholds maps a currency code to a decimal amount;
+// transfers above it require step-up, as do transfers in currencies without
+// a threshold.
+type StepUpConfig struct {
+	MaxAge             time.Duration
+	TransferThresholds map[string]string
+}
+
+// Tracing exporters
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"   // OTLP over HTTP, e.g. to an OpenTelemetry Collector or Jaeger
+	TracingExporterStdout = "stdout" // Pretty-printed spans, for local debugging
+)
+
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string // host:port of the OTLP/HTTP receiver
+	OTLPInsecure bool
+	SampleRatio  float64 // Fraction of new traces to sample; child spans follow their parent
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration // Default timeout of a dependency check
+	CacheTTL     time.Duration // How long a check result is reused across probes
+	DrainDelay   time.Duration // Time to report not ready before shutting down the server
+}
+
+type AccessLogConfig struct {
+	SampleRate    float64       // Fraction of successful requests logged; errors are always logged
+	SlowThreshold time.Duration // Requests slower than this are always logged
+}
+
+// FXConfig controls cross-currency transfers. SpreadBPS is the margin, in
+// basis points of the market rate, taken on conversions.
+type FXConfig struct {
+	QuoteTTL  time.Duration // How long a quoted rate is honoured
+	SpreadBPS int
+	RatesFile string // JSON rates for the static provider, e.g. {"USD/EUR": "0.92"}
+}
+
+// RateLimitConfig holds request limits per window.
+// Login and transfer endpoints get their own, stricter limits.
+type RateLimitConfig struct {
+	Enabled        bool
+	DefaultLimit   int
+	DefaultWindow  time.Duration
+	LoginLimit     int
+	LoginWindow    time.Duration
+	TransferLimit  int
+	TransferWindow time.Duration
 }
 
 // getDefaultPort returns the default port for each service according to PRD
@@ -71,12 +219,17 @@ func Load(serviceName string) (*Config, error) {
 	
 	servicePortEnv := fmt.Sprintf("%s_PORT", strings.ToUpper(serviceName))
 	defaultPort := getDefaultPort(serviceName)
+	environment := getEnv("ENV", "dev")
 	
 	cfg := &Config{
 		Service: ServiceConfig{
 			Name:        serviceName,
 			Port:        getEnv(servicePortEnv, getEnv("PORT", defaultPort)),
-			Environment: getEnv("ENV", "dev"),
+			Environment: environment,
+		},
+		AccessLog: AccessLogConfig{
+			SampleRate:    getEnvAsFloat("ACCESS_LOG_SAMPLE_RATE", 1.0),
+			SlowThreshold: getEnvAsDuration("ACCESS_LOG_SLOW_THRESHOLD", time.Second),
 		},
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "localhost"),
@@ -93,6 +246,23 @@ func Load(serviceName string) (*Config, error) {
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
+
+			Mode:             getEnv("REDIS_MODE", RedisModeStandalone),
+			Addrs:            getEnvAsSlice("REDIS_ADDRS", nil),
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+
+			PoolSize:     getEnvAsInt("REDIS_POOL_SIZE", 10),
+			MinIdleConns: getEnvAsInt("REDIS_MIN_IDLE_CONNS", 5),
+			DialTimeout:  getEnvAsDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+			ReadTimeout:  getEnvAsDuration("REDIS_READ_TIMEOUT", 3*time.Second),
+			WriteTimeout: getEnvAsDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+
+			TLSEnabled: getEnvAsBool("REDIS_TLS_ENABLED", false),
+			TLSCACert:  getEnv("REDIS_TLS_CA_CERT", ""),
+
+			KeyPrefix: getEnv("REDIS_KEY_PREFIX", fmt.Sprintf("mercuria:%s", environment)),
+			Service:   serviceName,
 		},
 		Kafka: KafkaConfig{
 			Brokers: []string{getEnv("KAFKA_BROKERS", "localhost:9092")},
@@ -102,22 +272,140 @@ func Load(serviceName string) (*Config, error) {
 			Secret:          getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 			AccessTokenTTL:  getEnvAsDuration("JWT_ACCESS_TTL", 15*time.Minute),
 			RefreshTokenTTL: getEnvAsDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+			Algorithm:       getEnv("JWT_ALGORITHM", "HS256"),
+			Issuer:          getEnv("JWT_ISSUER", "mercuria-auth"),
+			Audience:        getEnv("JWT_AUDIENCE", "mercuria"),
+			SigningKeysDir:  getEnv("JWT_SIGNING_KEYS_DIR", ""),
+			JWKSURL:         getEnv("JWT_JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+			JWKSCacheTTL:    getEnvAsDuration("JWT_JWKS_CACHE_TTL", 10*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			DefaultLimit:   getEnvAsInt("RATE_LIMIT_DEFAULT", 100),
+			DefaultWindow:  getEnvAsDuration("RATE_LIMIT_DEFAULT_WINDOW", time.Minute),
+			LoginLimit:     getEnvAsInt("RATE_LIMIT_LOGIN", 5),
+			LoginWindow:    getEnvAsDuration("RATE_LIMIT_LOGIN_WINDOW", time.Minute),
+			TransferLimit:  getEnvAsInt("RATE_LIMIT_TRANSFER", 20),
+			TransferWindow: getEnvAsDuration("RATE_LIMIT_TRANSFER_WINDOW", time.Minute),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvAsSlice("CORS_ALLOWED_ORIGINS", defaultCORSOrigins(environment)),
+			AllowedMethods:   getEnvAsSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+			AllowedHeaders:   getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"}),
+			ExposedHeaders:   getEnvAsSlice("CORS_EXPOSED_HEADERS", []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           getEnvAsDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Password: PasswordConfig{
+			MinLength:     getEnvAsInt("PASSWORD_MIN_LENGTH", 10),
+			RequireUpper:  getEnvAsBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  getEnvAsBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  getEnvAsBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+			BcryptCost:    getEnvAsInt("PASSWORD_BCRYPT_COST", 12),
+		},
+		Lockout: LockoutConfig{
+			FailureWindow:   getEnvAsDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			MaxFailures:     getEnvAsInt("LOGIN_MAX_FAILURES", 10),
+			LockoutDuration: getEnvAsDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			DelayAfter:      getEnvAsInt("LOGIN_DELAY_AFTER", 3),
+			DelayBase:       getEnvAsDuration("LOGIN_DELAY_BASE", time.Second),
+			DelayMax:        getEnvAsDuration("LOGIN_DELAY_MAX", 30*time.Second),
+			MaxIPFailures:   getEnvAsInt("LOGIN_MAX_IP_FAILURES", 50),
+		},
+		MFA: MFAConfig{
+			Issuer:        getEnv("MFA_ISSUER", "Mercuria"),
+			ChallengeTTL:  getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+			EnrollmentTTL: getEnvAsDuration("MFA_ENROLLMENT_TTL", 10*time.Minute),
+			RecoveryCodes: getEnvAsInt("MFA_RECOVERY_CODES", 10),
+		},
+		StepUp: StepUpConfig{
+			MaxAge: getEnvAsDuration("STEPUP_MAX_AGE", 5*time.Minute),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", TracingExporterNone),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: getEnvAsBool("TRACING_OTLP_INSECURE", environment != "production"),
+			SampleRatio:  getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Health: HealthConfig{
+			CheckTimeout: getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			CacheTTL:     getEnvAsDuration("HEALTH_CACHE_TTL", 2*time.Second),
+			DrainDelay:   getEnvAsDuration("HEALTH_DRAIN_DELAY", 5*time.Second),
+		},
+		FX: FXConfig{
+			QuoteTTL:  getEnvAsDuration("FX_QUOTE_TTL", 30*time.Second),
+			SpreadBPS: getEnvAsInt("FX_SPREAD_BPS", 50),
+			RatesFile: getEnv("FX_RATES_FILE", ""),
 		},
 	}
 
+	thresholds, err := parseThresholds(getEnvAsSlice("STEPUP_TRANSFER_THRESHOLDS", defaultStepUpThresholds))
+	if err != nil {
+		return nil, fmt.Errorf("STEPUP_TRANSFER_THRESHOLDS: %w", err)
+	}
+	cfg.StepUp.TransferThresholds = thresholds
+
+	trustedProxies, err := parsePrefixes(getEnvAsSlice("TRUSTED_PROXIES", nil))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	cfg.Service.TrustedProxies = trustedProxies
+
+	if cfg.AccessLog.SampleRate < 0 || cfg.AccessLog.SampleRate > 1 {
+		return nil, fmt.Errorf("ACCESS_LOG_SAMPLE_RATE must be between 0 and 1, got %v", cfg.AccessLog.SampleRate)
+	}
+
+	switch cfg.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterOTLP, TracingExporterStdout:
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER must be none, otlp or stdout, got %q", cfg.Tracing.Exporter)
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", cfg.Tracing.SampleRatio)
+	}
+
+	if cfg.CORS.AllowCredentials && slices.Contains(cfg.CORS.AllowedOrigins, "*") {
+		return nil, fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be combined with CORS_ALLOWED_ORIGINS=*")
+	}
+
+	if cfg.FX.QuoteTTL <= 0 {
+		return nil, fmt.Errorf("FX_QUOTE_TTL must be positive, got %v", cfg.FX.QuoteTTL)
+	}
+	if cfg.FX.SpreadBPS < 0 || cfg.FX.SpreadBPS >= 10000 {
+		return nil, fmt.Errorf("FX_SPREAD_BPS must be between 0 and 9999, got %d", cfg.FX.SpreadBPS)
+	}
+
 	// Validation for production
 	if cfg.Service.Environment == "production" {
-		if cfg.JWT.Secret == "your-secret-key-change-in-production" {
+		if cfg.JWT.Algorithm == "HS256" && cfg.JWT.Secret == "your-secret-key-change-in-production" {
 			return nil, fmt.Errorf("JWT_SECRET must be set in production")
 		}
 		if cfg.Database.Password == "postgres" {
 			return nil, fmt.Errorf("DB_PASSWORD must be set in production")
 		}
+		if len(cfg.CORS.AllowedOrigins) == 0 {
+			return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS must be set in production")
+		}
+		for _, origin := range cfg.CORS.AllowedOrigins {
+			if origin == "*" {
+				return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS must list the frontend origins in production, not *")
+			}
+		}
 	}
 
 	return cfg, nil
 }
 
+// defaultCORSOrigins allows local frontends outside production; production
+// must name its frontend in CORS_ALLOWED_ORIGINS
+func defaultCORSOrigins(environment string) []string {
+	if environment == "production" {
+		return nil
+	}
+	return []string{"http://localhost:*", "http://127.0.0.1:*"}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -134,6 +422,85 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// parsePrefixes parses CIDRs, and single IPs as /32 or /128 prefixes
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", value, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// defaultStepUpThresholds are roughly 1000 USD in each supported currency
+var defaultStepUpThresholds = []string{"USD:1000.00", "EUR:1000.00", "GBP:800.00", "JPY:150000", "IDR:15000000"}
+
+// parseThresholds parses "CUR:amount" pairs, e.g. "USD:1000.00"
+func parseThresholds(values []string) (map[string]string, error) {
+	thresholds := make(map[string]string, len(values))
+	for _, value := range values {
+		currency, amount, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid threshold %q, expected CURRENCY:AMOUNT", value)
+		}
+		m, err := money.Parse(amount, currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %w", value, err)
+		}
+		if m.IsNegative() {
+			return nil, fmt.Errorf("invalid threshold %q: must not be negative", value)
+		}
+		thresholds[currency] = amount
+	}
+	return thresholds, nil
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice splits a comma-separated variable, dropping empty entries
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
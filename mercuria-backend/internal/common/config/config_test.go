@@ -46,6 +46,128 @@ func TestLoad(t *testing.T)  {
 			},
 			wantErr: true,
 		},
+		{
+			name:        "production without CORS origins should fail",
+			serviceName: "auth",
+			envVars: map[string]string{
+				"ENV":         "production",
+				"JWT_SECRET":  "prod-secret",
+				"DB_PASSWORD": "prod-password",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "production with wildcard CORS origin should fail",
+			serviceName: "auth",
+			envVars: map[string]string{
+				"ENV":                  "production",
+				"JWT_SECRET":           "prod-secret",
+				"DB_PASSWORD":          "prod-password",
+				"CORS_ALLOWED_ORIGINS": "*",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "production serving the frontend",
+			serviceName: "auth",
+			envVars: map[string]string{
+				"ENV":                  "production",
+				"JWT_SECRET":           "prod-secret",
+				"DB_PASSWORD":          "prod-password",
+				"CORS_ALLOWED_ORIGINS": "https://app.mercuria.io",
+			},
+			wantErr: false,
+		},
+		{
+			name:        "wildcard CORS origin with credentials should fail",
+			serviceName: "auth",
+			envVars: map[string]string{
+				"CORS_ALLOWED_ORIGINS":   "*",
+				"CORS_ALLOW_CREDENTIALS": "true",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "invalid step-up threshold should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"STEPUP_TRANSFER_THRESHOLDS": "USD:a lot",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "step-up threshold without currency should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"STEPUP_TRANSFER_THRESHOLDS": "1000.00",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "step-up threshold in unknown currency should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"STEPUP_TRANSFER_THRESHOLDS": "USD:1000.00,CHF:900.00",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "step-up threshold finer than the currency should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"STEPUP_TRANSFER_THRESHOLDS": "JPY:1000.5",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "trusted proxies",
+			serviceName: "wallet",
+			envVars: map[string]string{
+				"TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.10,fd00::/8",
+			},
+			wantErr: false,
+		},
+		{
+			name:        "invalid trusted proxy should fail",
+			serviceName: "wallet",
+			envVars: map[string]string{
+				"TRUSTED_PROXIES": "10.0.0.0/33",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "unknown tracing exporter should fail",
+			serviceName: "wallet",
+			envVars: map[string]string{
+				"TRACING_EXPORTER": "zipkin",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "tracing sample ratio above 1 should fail",
+			serviceName: "wallet",
+			envVars: map[string]string{
+				"TRACING_EXPORTER":     "otlp",
+				"TRACING_SAMPLE_RATIO": "1.5",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "negative FX spread should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"FX_SPREAD_BPS": "-5",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "zero FX quote TTL should fail",
+			serviceName: "transaction",
+			envVars: map[string]string{
+				"FX_QUOTE_TTL": "0s",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -88,4 +210,20 @@ func TestGetEnvAsDuration(t *testing.T) {
 	if duration != 2*time.Minute {
 		t.Errorf("Expected 2m, got %v", duration)
 	}
-}
\ No newline at end of file
+}
+
+func TestGetEnvAsSlice(t *testing.T) {
+	os.Setenv("TEST_SLICE", "sentinel-1:26379, sentinel-2:26379,,sentinel-3:26379")
+	defer os.Unsetenv("TEST_SLICE")
+
+	values := getEnvAsSlice("TEST_SLICE", nil)
+	if len(values) != 3 || values[1] != "sentinel-2:26379" {
+		t.Errorf("Expected 3 trimmed addresses, got %v", values)
+	}
+
+	// Test default value
+	values = getEnvAsSlice("NON_EXISTENT", []string{"localhost:6379"})
+	if len(values) != 1 || values[0] != "localhost:6379" {
+		t.Errorf("Expected default value, got %v", values)
+	}
+}
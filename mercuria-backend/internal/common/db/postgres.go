@@ -8,7 +8,11 @@ import (
 
 	"github.com/kmassidik/mercuria/internal/common/config"
 	"github.com/kmassidik/mercuria/internal/common/logger"
-	_ "github.com/lib/pq"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type DB struct {
@@ -18,17 +22,19 @@ type DB struct {
 
 type TxFunc func(ctx context.Context, tx *sql.Tx) error
 
-// Connect establishes a connection to PostgreSQL
+// Connect establishes a connection to PostgreSQL. Queries run inside a trace
+// are recorded as client spans.
 func Connect(cfg config.DatabaseConfig, log *logger.Logger) (*DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName,
 	)
 
-	db, err := sql.Open("postgres", dsn)
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db := sql.OpenDB(tracedConnector{Connector: connector, dbName: cfg.DBName})
 
 	// Configure connection pool
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
@@ -45,6 +51,11 @@ func Connect(cfg config.DatabaseConfig, log *logger.Logger) (*DB, error) {
 
 	log.Infof("Connected to database: %s", cfg.DBName)
 
+	// Exports the sql.DBStats pool gauges, e.g. go_sql_in_use_connections
+	if err := metrics.Register(collectors.NewDBStatsCollector(db, cfg.DBName)); err != nil {
+		log.Warnf("Failed to register database pool metrics: %v", err)
+	}
+
 	return &DB{DB: db, logger: log}, nil
 }
 
@@ -62,8 +73,18 @@ func (db *DB) Health(ctx context.Context) error {
 	return db.PingContext(ctx)
 }
 
-// WithTransaction executes a function within a transaction
-func (db *DB) WithTransaction(ctx context.Context, fn TxFunc) error { // <- Change fn's type
+// WithTransaction executes a function within a transaction, under a span
+// that parents the spans of its queries
+func (db *DB) WithTransaction(ctx context.Context, fn TxFunc) (err error) { // <- Change fn's type
+    ctx, span := tracing.Tracer().Start(ctx, "db.transaction")
+    defer func() {
+        if err != nil {
+            span.RecordError(err)
+            span.SetStatus(codes.Error, "transaction failed")
+        }
+        span.End()
+    }()
+
     tx, err := db.BeginTx(ctx, nil)
     if err != nil {
         return fmt.Errorf("failed to begin transaction: %w", err)
@@ -0,0 +1,266 @@
+// Package devca is a small certificate authority for development and tests.
+// It issues the per-service certificates mtls.Config expects: Organization
+// Mercuria, the service name as CN, SPIFFE ID and DNS SANs. Production
+// certificates should come from a real internal CA.
+package devca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Organization matches mtls.DefaultOrganization; mtls tests use this
+	// package, so it cannot import mtls
+	Organization = "Mercuria"
+
+	DefaultTrustDomain  = "mercuria.local"
+	DefaultCALifetime   = 365 * 24 * time.Hour
+	DefaultCertLifetime = 30 * 24 * time.Hour
+)
+
+// Usage selects what an issued certificate may be used for
+type Usage int
+
+const (
+	UsageServer Usage = 1 << iota
+	UsageClient
+)
+
+// CA signs service certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// Certificate is an issued certificate with its private key
+type Certificate struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// IssueOptions describes a service certificate
+type IssueOptions struct {
+	Service     string        // CN and SPIFFE ID path, e.g. "wallet"
+	DNSNames    []string      // Extra DNS SANs; the service name and localhost are always included
+	IPAddresses []net.IP      // Extra IP SANs; 127.0.0.1 and ::1 are always included
+	TrustDomain string        // SPIFFE trust domain, DefaultTrustDomain if empty
+	Lifetime    time.Duration // DefaultCertLifetime if zero
+	Usage       Usage         // Both server and client if zero
+}
+
+// New creates a self-signed CA
+func New(lifetime time.Duration) (*CA, error) {
+	if lifetime <= 0 {
+		lifetime = DefaultCALifetime
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Mercuria Development CA",
+			Organization: []string{Organization},
+		},
+		NotBefore:             now.Add(-5 * time.Minute), // Tolerate clock skew
+		NotAfter:              now.Add(lifetime),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// Load reads a CA written by WriteFiles
+func Load(certPath, keyPath string) (*CA, error) {
+	tlsCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certPath)
+	}
+
+	key, ok := tlsCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA key type %T", tlsCert.PrivateKey)
+	}
+
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// Pool returns a pool trusting only this CA
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// WriteFiles writes the CA certificate and key as PEM
+func (ca *CA) WriteFiles(certPath, keyPath string) error {
+	return writeFiles(ca.Cert, ca.Key, certPath, keyPath)
+}
+
+// Issue signs a certificate for a service
+func (ca *CA) Issue(opts IssueOptions) (*Certificate, error) {
+	if opts.Service == "" {
+		return nil, fmt.Errorf("service name is required")
+	}
+	if opts.TrustDomain == "" {
+		opts.TrustDomain = DefaultTrustDomain
+	}
+	if opts.Lifetime <= 0 {
+		opts.Lifetime = DefaultCertLifetime
+	}
+	if opts.Usage == 0 {
+		opts.Usage = UsageServer | UsageClient
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	spiffeID := &url.URL{Scheme: "spiffe", Host: opts.TrustDomain, Path: "/service/" + opts.Service}
+
+	var extUsage []x509.ExtKeyUsage
+	if opts.Usage&UsageServer != 0 {
+		extUsage = append(extUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if opts.Usage&UsageClient != 0 {
+		extUsage = append(extUsage, x509.ExtKeyUsageClientAuth)
+	}
+
+	now := time.Now()
+	notAfter := now.Add(opts.Lifetime)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   opts.Service,
+			Organization: []string{Organization},
+		},
+		DNSNames:    append([]string{opts.Service, "localhost"}, opts.DNSNames...),
+		IPAddresses: append([]net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}, opts.IPAddresses...),
+		URIs:        []*url.URL{spiffeID},
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: extUsage,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s: %w", opts.Service, err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return &Certificate{Cert: cert, Key: key}, nil
+}
+
+// TLSCertificate returns the certificate for use in a tls.Config
+func (c *Certificate) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.Cert.Raw},
+		PrivateKey:  c.Key,
+		Leaf:        c.Cert,
+	}
+}
+
+// WriteFiles writes the certificate and key as PEM
+func (c *Certificate) WriteFiles(certPath, keyPath string) error {
+	return writeFiles(c.Cert, c.Key, certPath, keyPath)
+}
+
+func writeFiles(cert *x509.Certificate, key crypto.Signer, certPath, keyPath string) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	return nil
+}
+
+func newKey() (crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
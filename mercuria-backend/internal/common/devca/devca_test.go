@@ -0,0 +1,88 @@
+package devca
+
+import (
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/mtls"
+)
+
+func TestIssue(t *testing.T) {
+	ca, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	cert, err := ca.Issue(IssueOptions{
+		Service:  "wallet",
+		DNSNames: []string{"mercuria-wallet"},
+		Lifetime: 24 * time.Hour,
+		Usage:    UsageServer,
+	})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	_, err = cert.Cert.Verify(x509.VerifyOptions{
+		Roots:     ca.Pool(),
+		DNSName:   "mercuria-wallet",
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		t.Errorf("Expected certificate to verify as a server certificate: %v", err)
+	}
+
+	_, err = cert.Cert.Verify(x509.VerifyOptions{
+		Roots:     ca.Pool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err == nil {
+		t.Error("Expected server-only certificate to be rejected as a client certificate")
+	}
+
+	// Certificates never outlive their CA
+	if cert.Cert.NotAfter.After(ca.Cert.NotAfter) {
+		t.Errorf("Expected expiry capped at %v, got %v", ca.Cert.NotAfter, cert.Cert.NotAfter)
+	}
+
+	identity, err := mtls.IdentityFromCertificate(cert.Cert)
+	if err != nil {
+		t.Fatalf("IdentityFromCertificate failed: %v", err)
+	}
+	if identity.Name != "wallet" || identity.TrustDomain != DefaultTrustDomain {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+	if err := mtls.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert.Cert, ca.Cert}}); err != nil {
+		t.Errorf("Expected certificate to pass peer verification: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca", "ca.crt")
+	keyPath := filepath.Join(dir, "ca", "ca.key")
+
+	ca, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := ca.WriteFiles(certPath, keyPath); err != nil {
+		t.Fatalf("WriteFiles failed: %v", err)
+	}
+
+	loaded, err := Load(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// A certificate issued by the loaded CA is trusted by the original
+	cert, err := loaded.Issue(IssueOptions{Service: "ledger"})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if _, err := cert.Cert.Verify(x509.VerifyOptions{Roots: ca.Pool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("Expected certificate from loaded CA to verify: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+// RateScale is the number of decimal places rates are locked at
+const RateScale = 10
+
+// pivotCurrency is used for cross rates the provider does not quote directly
+const pivotCurrency = "USD"
+
+var (
+	ErrRateNotFound   = errors.New("exchange rate not found")
+	ErrSameCurrency   = errors.New("source and target currency are the same")
+	ErrAmountTooSmall = errors.New("amount too small to convert")
+	ErrQuoteNotFound  = errors.New("fx quote not found")
+	ErrQuoteExpired   = errors.New("fx quote expired")
+	ErrQuoteMismatch  = errors.New("fx quote does not match the transfer")
+	ErrUnbalanced     = errors.New("postings do not balance")
+)
+
+// Rate is the number of units of To one unit of From buys
+type Rate struct {
+	From  string
+	To    string
+	Value *big.Rat
+	AsOf  time.Time
+}
+
+// String formats the rate with RateScale decimal places
+func (r Rate) String() string {
+	return r.Value.FloatString(RateScale)
+}
+
+// RateProvider returns market (mid) exchange rates
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// StaticProvider serves rates from a fixed table, for tests and for
+// environments without a market data feed. Rates for the inverse pair and
+// cross rates through USD are derived.
+type StaticProvider struct {
+	mu    sync.RWMutex
+	rates map[string]*big.Rat
+	asOf  time.Time
+}
+
+// NewStaticProvider creates a provider from rates keyed by "FROM/TO", e.g.
+// {"USD/EUR": "0.92"}
+func NewStaticProvider(rates map[string]string) (*StaticProvider, error) {
+	p := &StaticProvider{rates: make(map[string]*big.Rat), asOf: time.Now()}
+	for pair, value := range rates {
+		from, to, ok := strings.Cut(pair, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid currency pair %q", pair)
+		}
+		if err := p.Set(from, to, value); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// LoadStaticProvider creates a provider from a JSON file of rates keyed by
+// "FROM/TO"
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+	var rates map[string]string
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to decode rates file: %w", err)
+	}
+	return NewStaticProvider(rates)
+}
+
+// Set replaces the rate of a pair
+func (p *StaticProvider) Set(from, to, value string) error {
+	if !money.IsSupported(from) || !money.IsSupported(to) {
+		return fmt.Errorf("%w: %s/%s", money.ErrUnknownCurrency, from, to)
+	}
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok || rate.Sign() <= 0 {
+		return fmt.Errorf("invalid rate %q for %s/%s", value, from, to)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates[from+"/"+to] = rate
+	p.asOf = time.Now()
+	return nil
+}
+
+// Rate returns the rate of a pair, its inverse, or the cross rate through USD
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (Rate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	value, ok := p.lookup(from, to)
+	if !ok && from != pivotCurrency && to != pivotCurrency {
+		toPivot, ok1 := p.lookup(from, pivotCurrency)
+		fromPivot, ok2 := p.lookup(pivotCurrency, to)
+		if ok1 && ok2 {
+			value, ok = new(big.Rat).Mul(toPivot, fromPivot), true
+		}
+	}
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+	}
+	return Rate{From: from, To: to, Value: value, AsOf: p.asOf}, nil
+}
+
+func (p *StaticProvider) lookup(from, to string) (*big.Rat, bool) {
+	if from == to {
+		return big.NewRat(1, 1), true
+	}
+	if rate, ok := p.rates[from+"/"+to]; ok {
+		return new(big.Rat).Set(rate), true
+	}
+	if rate, ok := p.rates[to+"/"+from]; ok {
+		return new(big.Rat).Inv(rate), true
+	}
+	return nil, false
+}
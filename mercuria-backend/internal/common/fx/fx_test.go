@@ -0,0 +1,265 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+func newTestProvider(t *testing.T) *StaticProvider {
+	t.Helper()
+	provider, err := NewStaticProvider(map[string]string{
+		"USD/EUR": "0.92",
+		"USD/JPY": "150.25",
+		"GBP/USD": "1.25",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func TestStaticProvider(t *testing.T) {
+	provider := newTestProvider(t)
+
+	tests := []struct {
+		from        string
+		to          string
+		expected    string
+		expectedErr error
+	}{
+		{"USD", "EUR", "0.9200000000", nil},
+		{"EUR", "USD", "1.0869565217", nil}, // Inverse
+		{"GBP", "EUR", "1.1500000000", nil}, // Cross through USD
+		{"EUR", "JPY", "163.3152173913", nil},
+		{"USD", "USD", "1.0000000000", nil},
+		{"USD", "IDR", "", ErrRateNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"/"+tt.to, func(t *testing.T) {
+			rate, err := provider.Rate(context.Background(), tt.from, tt.to)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && rate.String() != tt.expected {
+				t.Errorf("Expected rate %s, got %s", tt.expected, rate)
+			}
+		})
+	}
+
+	for _, rates := range []map[string]string{
+		{"USDEUR": "0.92"},
+		{"USD/CHF": "0.88"},
+		{"USD/EUR": "-1"},
+		{"USD/EUR": "abc"},
+	} {
+		if _, err := NewStaticProvider(rates); err == nil {
+			t.Errorf("Expected an error for %v", rates)
+		}
+	}
+}
+
+func TestLoadStaticProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"USD/IDR": "15600"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write rates file: %v", err)
+	}
+
+	provider, err := LoadStaticProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to load rates: %v", err)
+	}
+	rate, err := provider.Rate(context.Background(), "USD", "IDR")
+	if err != nil || rate.String() != "15600.0000000000" {
+		t.Errorf("Expected 15600, got %v (%v)", rate, err)
+	}
+
+	if _, err := LoadStaticProvider(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func newTestService(t *testing.T, provider RateProvider, spreadBPS int) *Service {
+	t.Helper()
+	return NewService(provider, NewMemoryQuoteStore(), config.FXConfig{
+		QuoteTTL:  30 * time.Second,
+		SpreadBPS: spreadBPS,
+	})
+}
+
+func TestQuote(t *testing.T) {
+	provider := newTestProvider(t)
+	svc := newTestService(t, provider, 50)
+	ctx := context.Background()
+	source := money.MustParse("100.00", "USD")
+
+	q, err := svc.CreateQuote(ctx, "user-1", source, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+	// 0.92 less 0.5%
+	if q.Rate != "0.9154000000" {
+		t.Errorf("Expected rate 0.9154, got %s", q.Rate)
+	}
+	if q.Target.String() != "91.54" || q.Target.Currency() != "EUR" {
+		t.Errorf("Expected 91.54 EUR, got %s %s", q.Target, q.Target.Currency())
+	}
+	if q.ExpiresAt.Sub(q.CreatedAt) != 30*time.Second {
+		t.Errorf("Expected quote to expire after 30s, got %v", q.ExpiresAt.Sub(q.CreatedAt))
+	}
+
+	// The locked rate holds even if the market moves
+	provider.Set("USD", "EUR", "0.90")
+
+	conv, err := svc.ExecuteQuote(ctx, q.ID, "user-1", source, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to execute quote: %v", err)
+	}
+	if conv.Target.String() != "91.54" {
+		t.Errorf("Expected 91.54 EUR credited, got %s", conv.Target)
+	}
+	if conv.MarketValue.String() != "90.00" {
+		t.Errorf("Expected market value 90.00, got %s", conv.MarketValue)
+	}
+	if conv.GainLoss.String() != "-1.54" {
+		t.Errorf("Expected a loss of 1.54, got %s", conv.GainLoss)
+	}
+
+	if _, err := svc.ExecuteQuote(ctx, q.ID, "user-1", source, "EUR"); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("Expected ErrQuoteNotFound on reuse, got %v", err)
+	}
+}
+
+func TestExecuteQuoteErrors(t *testing.T) {
+	svc := newTestService(t, newTestProvider(t), 0)
+	ctx := context.Background()
+	source := money.MustParse("100.00", "USD")
+
+	q, err := svc.CreateQuote(ctx, "user-1", source, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+
+	rejected := []struct {
+		name        string
+		userID      string
+		source      money.Money
+		to          string
+		expectedErr error
+	}{
+		{"different amount", "user-1", money.MustParse("200.00", "USD"), "EUR", ErrQuoteMismatch},
+		{"different source currency", "user-1", money.MustParse("100.00", "GBP"), "EUR", ErrQuoteMismatch},
+		{"different target currency", "user-1", source, "JPY", ErrQuoteMismatch},
+		{"other user", "user-2", source, "EUR", ErrQuoteNotFound},
+	}
+	for _, tt := range rejected {
+		if _, err := svc.ExecuteQuote(ctx, q.ID, tt.userID, tt.source, tt.to); !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expectedErr, err)
+		}
+	}
+
+	// Rejected requests leave the quote for the matching transfer
+	if _, err := svc.ExecuteQuote(ctx, q.ID, "user-1", source, "EUR"); err != nil {
+		t.Errorf("Expected the quote to survive rejected requests, got %v", err)
+	}
+
+	q, err = svc.CreateQuote(ctx, "user-1", source, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+	svc.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := svc.ExecuteQuote(ctx, q.ID, "user-1", source, "EUR"); !errors.Is(err, ErrQuoteExpired) {
+		t.Errorf("Expected ErrQuoteExpired, got %v", err)
+	}
+
+	if _, err := svc.ExecuteQuote(ctx, "unknown", "user-1", source, "EUR"); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("Expected ErrQuoteNotFound, got %v", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	svc := newTestService(t, newTestProvider(t), 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		source         money.Money
+		to             string
+		expectedTarget string
+		expectedErr    error
+	}{
+		{"USD to JPY", money.MustParse("100.00", "USD"), "JPY", "15025", nil},
+		{"JPY to USD rounds down", money.MustParse("1000", "JPY"), "USD", "6.65", nil},
+		{"GBP to EUR", money.MustParse("10.00", "GBP"), "EUR", "11.50", nil},
+		{"too small", money.MustParse("1", "JPY"), "USD", "", ErrAmountTooSmall},
+		{"same currency", money.MustParse("10.00", "USD"), "USD", "", ErrSameCurrency},
+		{"no rate", money.MustParse("10.00", "USD"), "IDR", "", ErrRateNotFound},
+		{"unsupported currency", money.MustParse("10.00", "USD"), "CHF", "", money.ErrUnknownCurrency},
+		{"zero amount", money.Zero("USD"), "EUR", "", money.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conv, err := svc.Convert(ctx, tt.source, tt.to)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if conv.Target.String() != tt.expectedTarget {
+				t.Errorf("Expected %s %s, got %s", tt.expectedTarget, tt.to, conv.Target)
+			}
+			if err := Balanced(conv.Postings("wallet-a", "wallet-b")); err != nil {
+				t.Errorf("Expected balanced postings: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostings(t *testing.T) {
+	svc := newTestService(t, newTestProvider(t), 50)
+	conv, err := svc.Convert(context.Background(), money.MustParse("100.00", "USD"), "EUR")
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	postings := conv.Postings("wallet-a", "wallet-b")
+	expected := []struct {
+		account  string
+		typ      EntryType
+		amount   string
+		currency string
+	}{
+		{"wallet:wallet-a", Debit, "100.00", "USD"},
+		{"fx:position:USD", Credit, "100.00", "USD"},
+		{"fx:position:EUR", Debit, "92.00", "EUR"},
+		{"wallet:wallet-b", Credit, "91.54", "EUR"},
+		{"fx:gain_loss:EUR", Credit, "0.46", "EUR"},
+	}
+	if len(postings) != len(expected) {
+		t.Fatalf("Expected %d postings, got %d", len(expected), len(postings))
+	}
+	for i, e := range expected {
+		p := postings[i]
+		if p.Account != e.account || p.Type != e.typ || p.Amount.String() != e.amount || p.Amount.Currency() != e.currency {
+			t.Errorf("Posting %d: expected %s %s %s %s, got %s %s %s %s", i,
+				e.account, e.typ, e.amount, e.currency, p.Account, p.Type, p.Amount, p.Amount.Currency())
+		}
+	}
+	if err := Balanced(postings); err != nil {
+		t.Errorf("Expected balanced postings: %v", err)
+	}
+
+	// Dropping the gain leaves EUR unbalanced
+	if err := Balanced(postings[:4]); !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("Expected ErrUnbalanced, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package fx
+
+import (
+	"fmt"
+
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+// EntryType is the side of a ledger posting, matching ledger entry_type
+type EntryType string
+
+const (
+	Debit  EntryType = "debit"
+	Credit EntryType = "credit"
+)
+
+// Posting is one ledger entry of a transfer
+type Posting struct {
+	Account string      `json:"account"`
+	Type    EntryType   `json:"entry_type"`
+	Amount  money.Money `json:"amount"`
+}
+
+// WalletAccount is the ledger account of a wallet
+func WalletAccount(walletID string) string {
+	return "wallet:" + walletID
+}
+
+// PositionAccount holds the house's position in a currency: what it received
+// from senders and paid out to recipients of conversions
+func PositionAccount(currency string) string {
+	return "fx:position:" + currency
+}
+
+// GainLossAccount records FX gains (credits) and losses (debits) in a currency
+func GainLossAccount(currency string) string {
+	return "fx:gain_loss:" + currency
+}
+
+// Postings returns the double-entry postings of a cross-currency transfer.
+// Each leg balances in its own currency: the source leg moves the sent
+// amount into the source position, and the target leg pays the recipient
+// out of the target position at market value, with the difference booked
+// to FX gain/loss.
+func (c *Conversion) Postings(fromWalletID, toWalletID string) []Posting {
+	source, target := c.Source.Currency(), c.Target.Currency()
+
+	postings := []Posting{
+		{Account: WalletAccount(fromWalletID), Type: Debit, Amount: c.Source},
+		{Account: PositionAccount(source), Type: Credit, Amount: c.Source},
+		{Account: PositionAccount(target), Type: Debit, Amount: c.MarketValue},
+		{Account: WalletAccount(toWalletID), Type: Credit, Amount: c.Target},
+	}
+
+	switch {
+	case c.GainLoss.IsPositive():
+		postings = append(postings, Posting{Account: GainLossAccount(target), Type: Credit, Amount: c.GainLoss})
+	case c.GainLoss.IsNegative():
+		postings = append(postings, Posting{Account: GainLossAccount(target), Type: Debit, Amount: c.GainLoss.Neg()})
+	}
+	return postings
+}
+
+// Balanced checks that debits equal credits in every currency
+func Balanced(postings []Posting) error {
+	net := make(map[string]money.Money)
+	for _, p := range postings {
+		currency := p.Amount.Currency()
+		total, ok := net[currency]
+		if !ok {
+			total = money.Zero(currency)
+		}
+
+		var err error
+		switch p.Type {
+		case Debit:
+			total, err = total.Add(p.Amount)
+		case Credit:
+			total, err = total.Sub(p.Amount)
+		default:
+			err = fmt.Errorf("invalid entry type %q", p.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to balance postings: %w", err)
+		}
+		net[currency] = total
+	}
+
+	for currency, total := range net {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: %s is off by %s", ErrUnbalanced, currency, total)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package fx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+// Quote locks a rate for converting Source into Target until ExpiresAt. Only
+// the user it was issued to can execute it.
+type Quote struct {
+	ID        string      `json:"id"`
+	UserID    string      `json:"user_id"`
+	Source    money.Money `json:"source"`
+	Target    money.Money `json:"target"`
+	Rate      string      `json:"rate"`     // Locked rate, including the spread
+	MidRate   string      `json:"mid_rate"` // Market rate when quoted
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// QuoteStore keeps encoded quotes until they expire or are taken. Get and
+// Take return nil for missing quotes. It is implemented by redis.Client.
+type QuoteStore interface {
+	SaveFXQuote(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	GetFXQuote(ctx context.Context, id string) ([]byte, error)
+	TakeFXQuote(ctx context.Context, id string) ([]byte, error)
+}
+
+// Conversion is the outcome of converting a transfer amount. GainLoss is
+// what the spread and any market move since the quote earned (positive) or
+// cost (negative), in the target currency.
+type Conversion struct {
+	QuoteID     string      `json:"quote_id,omitempty"`
+	Source      money.Money `json:"source"`
+	Target      money.Money `json:"target"`       // Credited to the recipient
+	Rate        string      `json:"rate"`         // Rate the recipient got
+	MidRate     string      `json:"mid_rate"`     // Market rate at execution
+	MarketValue money.Money `json:"market_value"` // Source at MidRate
+	GainLoss    money.Money `json:"gain_loss"`
+}
+
+// Service quotes and executes currency conversions for transfers
+type Service struct {
+	provider RateProvider
+	store    QuoteStore
+	cfg      config.FXConfig
+	now      func() time.Time
+}
+
+func NewService(provider RateProvider, store QuoteStore, cfg config.FXConfig) *Service {
+	return &Service{
+		provider: provider,
+		store:    store,
+		cfg:      cfg,
+		now:      time.Now,
+	}
+}
+
+// CreateQuote locks the rate for converting source into the to currency
+// for cfg.QuoteTTL, for userID to execute
+func (s *Service) CreateQuote(ctx context.Context, userID string, source money.Money, to string) (*Quote, error) {
+	mid, err := s.midRate(ctx, source, to)
+	if err != nil {
+		return nil, err
+	}
+	rate := s.applySpread(mid.Value)
+	target, err := convert(source, rate, to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	q := &Quote{
+		ID:        newQuoteID(),
+		UserID:    userID,
+		Source:    source,
+		Target:    target,
+		Rate:      rate.FloatString(RateScale),
+		MidRate:   mid.String(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.QuoteTTL),
+	}
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fx quote: %w", err)
+	}
+	if err := s.store.SaveFXQuote(ctx, q.ID, data, s.cfg.QuoteTTL); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// ExecuteQuote converts source into the to currency at the rate locked by a
+// quote issued to userID. Each quote can be executed once; a transfer that
+// fails afterwards needs a new quote. The quote is only used up once it
+// matches the transfer, so a mismatched request can be corrected and retried.
+// The gain or loss is measured against the current market rate, or the rate
+// at quote time if the provider is unavailable.
+func (s *Service) ExecuteQuote(ctx context.Context, quoteID, userID string, source money.Money, to string) (*Conversion, error) {
+	data, err := s.store.GetFXQuote(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, quoteID)
+	}
+
+	var q Quote
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("failed to decode fx quote: %w", err)
+	}
+	// Other users' quotes are reported as missing, not as mismatched
+	if q.UserID != userID {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, quoteID)
+	}
+	if !s.now().Before(q.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteExpired, quoteID)
+	}
+	if !q.Source.Equal(source) || q.Target.Currency() != to {
+		return nil, fmt.Errorf("%w: quoted %s %s to %s, transfer is %s %s to %s", ErrQuoteMismatch,
+			q.Source, q.Source.Currency(), q.Target.Currency(), source, source.Currency(), to)
+	}
+
+	mid, ok := new(big.Rat).SetString(q.MidRate)
+	if !ok {
+		return nil, fmt.Errorf("failed to decode fx quote: invalid mid rate %q", q.MidRate)
+	}
+
+	// Quotes are never rewritten, so a successful take is of the quote just
+	// checked; a concurrent transfer that got there first leaves nothing
+	taken, err := s.store.TakeFXQuote(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if taken == nil {
+		return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, quoteID)
+	}
+
+	if current, err := s.provider.Rate(ctx, source.Currency(), to); err == nil {
+		mid = current.Value
+	}
+
+	return newConversion(q.ID, source, q.Target, q.Rate, mid)
+}
+
+// Convert converts source at the current rate, for transfers made without
+// a quote
+func (s *Service) Convert(ctx context.Context, source money.Money, to string) (*Conversion, error) {
+	mid, err := s.midRate(ctx, source, to)
+	if err != nil {
+		return nil, err
+	}
+	rate := s.applySpread(mid.Value)
+	target, err := convert(source, rate, to)
+	if err != nil {
+		return nil, err
+	}
+	return newConversion("", source, target, rate.FloatString(RateScale), mid.Value)
+}
+
+func (s *Service) midRate(ctx context.Context, source money.Money, to string) (Rate, error) {
+	if !source.IsPositive() {
+		return Rate{}, fmt.Errorf("%w: %s", money.ErrInvalidAmount, source)
+	}
+	if !money.IsSupported(to) {
+		return Rate{}, fmt.Errorf("%w: %q", money.ErrUnknownCurrency, to)
+	}
+	if source.Currency() == to {
+		return Rate{}, ErrSameCurrency
+	}
+	return s.provider.Rate(ctx, source.Currency(), to)
+}
+
+// applySpread returns the customer rate, mid * (1 - spread), truncated to
+// RateScale decimal places
+func (s *Service) applySpread(mid *big.Rat) *big.Rat {
+	rate := new(big.Rat).Mul(mid, big.NewRat(int64(10000-s.cfg.SpreadBPS), 10000))
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(RateScale), nil)
+	num := new(big.Int).Mul(rate.Num(), scale)
+	num.Quo(num, rate.Denom())
+	return new(big.Rat).SetFrac(num, scale)
+}
+
+// convert converts source at rate, rounding down so the recipient is never
+// credited more than the rate gives
+func convert(source money.Money, rate *big.Rat, to string) (money.Money, error) {
+	target, err := money.FromRat(new(big.Rat).Mul(source.Rat(), rate), to, money.RoundDown)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if !target.IsPositive() {
+		return money.Money{}, fmt.Errorf("%w: %s %s", ErrAmountTooSmall, source, source.Currency())
+	}
+	return target, nil
+}
+
+func newConversion(quoteID string, source, target money.Money, rate string, mid *big.Rat) (*Conversion, error) {
+	marketValue, err := money.FromRat(new(big.Rat).Mul(source.Rat(), mid), target.Currency(), money.RoundHalfEven)
+	if err != nil {
+		return nil, err
+	}
+	gainLoss, err := marketValue.Sub(target)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversion{
+		QuoteID:     quoteID,
+		Source:      source,
+		Target:      target,
+		Rate:        rate,
+		MidRate:     mid.FloatString(RateScale),
+		MarketValue: marketValue,
+		GainLoss:    gainLoss,
+	}, nil
+}
+
+func newQuoteID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate quote id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// MemoryQuoteStore is an in-process QuoteStore, for tests and single-instance
+// deployments
+type MemoryQuoteStore struct {
+	mu     sync.Mutex
+	quotes map[string]memoryQuote
+}
+
+type memoryQuote struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryQuoteStore() *MemoryQuoteStore {
+	return &MemoryQuoteStore{quotes: make(map[string]memoryQuote)}
+}
+
+func (m *MemoryQuoteStore) SaveFXQuote(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotes[id] = memoryQuote{data: data, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryQuoteStore) GetFXQuote(ctx context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.quotes[id]
+	if !ok || time.Now().After(q.expiresAt) {
+		return nil, nil
+	}
+	return q.data, nil
+}
+
+func (m *MemoryQuoteStore) TakeFXQuote(ctx context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.quotes[id]
+	delete(m.quotes, id)
+	if !ok || time.Now().After(q.expiresAt) {
+		return nil, nil
+	}
+	return q.data, nil
+}
@@ -0,0 +1,260 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+// Probe paths
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Status of a check or of the whole service
+type Status string
+
+const (
+	StatusOK          Status = "ok"
+	StatusDegraded    Status = "degraded"    // A non-critical dependency is failing; still ready
+	StatusUnavailable Status = "unavailable" // A critical dependency is failing; not ready
+	StatusDraining    Status = "draining"    // Shutting down; not ready
+	StatusFail        Status = "fail"        // Status of a failed check
+)
+
+// CheckFunc reports whether a dependency is usable. db.DB.Health,
+// redis.Client.Health and kafka.Producer.Ping are CheckFuncs.
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named dependency check
+type Check struct {
+	Name     string
+	Func     CheckFunc
+	Timeout  time.Duration // Zero uses the checker's default timeout
+	Critical bool          // A failing critical check makes the service not ready
+}
+
+// CheckResult is the outcome of one check, as reported by /readyz
+type CheckResult struct {
+	Status    Status    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the body of /readyz
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the registered checks for the readiness probe. Results are
+// cached for CacheTTL, and concurrent probes share one run of each check, so
+// frequent probes from several load balancers do not hammer dependencies.
+type Checker struct {
+	cfg    config.HealthConfig
+	logger *logger.Logger
+
+	mu      sync.RWMutex
+	checks  []Check
+	results map[string]CheckResult
+
+	group    singleflight.Group
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker with no checks
+func NewChecker(cfg config.HealthConfig, log *logger.Logger) *Checker {
+	return &Checker{
+		cfg:     cfg,
+		logger:  log,
+		results: make(map[string]CheckResult),
+	}
+}
+
+// Register adds checks. Check names must be unique.
+func (c *Checker) Register(checks ...Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, checks...)
+}
+
+// Drain marks the service as shutting down, so /readyz fails and load
+// balancers stop sending new requests. Liveness is unaffected.
+func (c *Checker) Drain() {
+	if !c.draining.Swap(true) {
+		c.logger.Info("Draining: reporting not ready")
+	}
+}
+
+// Draining reports whether Drain has been called
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Shutdown drains, waits DrainDelay for load balancers to notice, then shuts
+// down srv, waiting for in-flight requests until ctx is done
+func (c *Checker) Shutdown(ctx context.Context, srv *http.Server) error {
+	c.Drain()
+
+	select {
+	case <-time.After(c.cfg.DrainDelay):
+	case <-ctx.Done():
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
+
+// Check runs the registered checks, reusing cached results
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make([]Check, len(c.checks))
+	copy(checks, c.checks)
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.result(ctx, check)
+
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	if c.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// result returns the cached result of check, running it if the cache expired
+func (c *Checker) result(ctx context.Context, check Check) CheckResult {
+	c.mu.RLock()
+	cached, ok := c.results[check.Name]
+	c.mu.RUnlock()
+	if ok && time.Since(cached.CheckedAt) < c.cfg.CacheTTL {
+		return cached
+	}
+
+	v, _, _ := c.group.Do(check.Name, func() (interface{}, error) {
+		// Detached from the probe request, so one cancelled probe does not
+		// cache a failure for the others sharing this run
+		result := c.run(context.WithoutCancel(ctx), check)
+
+		c.mu.Lock()
+		c.results[check.Name] = result
+		c.mu.Unlock()
+		return result, nil
+	})
+	return v.(CheckResult)
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = c.cfg.CheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runCheck(ctx, check.Func)
+
+	result := CheckResult{
+		Status:    StatusOK,
+		Critical:  check.Critical,
+		Duration:  time.Since(start).Round(time.Microsecond).String(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		c.logger.WarnContext(ctx, "Health check failed", "check", check.Name, "critical", check.Critical, "error", err)
+	}
+	return result
+}
+
+// runCheck returns when fn does or when ctx expires, for checks that ignore
+// their context
+func runCheck(ctx context.Context, fn CheckFunc) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
+
+// RegisterRoutes mounts the liveness and readiness probes
+func (c *Checker) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+LivenessPath, c.Liveness)
+	mux.HandleFunc("GET "+ReadinessPath, c.Readiness)
+}
+
+// Liveness reports that the process is up and serving. It checks no
+// dependencies, so an outage never gets healthy instances restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readiness reports whether the service can take traffic, with the result of
+// each dependency check. It returns 503 when a critical check fails or the
+// service is draining.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status == StatusUnavailable || report.Status == StatusDraining {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+func testHealthConfig() config.HealthConfig {
+	return config.HealthConfig{
+		CheckTimeout: 50 * time.Millisecond,
+		CacheTTL:     time.Minute,
+	}
+}
+
+func okCheck(context.Context) error   { return nil }
+func failCheck(context.Context) error { return errors.New("connection refused") }
+
+func doReadiness(t *testing.T, checker *Checker) (int, Report) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	checker.RegisterRoutes(mux)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", ReadinessPath, nil))
+
+	var report Report
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	return rr.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		checks         []Check
+		expectedStatus Status
+		expectedCode   int
+	}{
+		{
+			name:           "no checks",
+			expectedStatus: StatusOK,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name: "all passing",
+			checks: []Check{
+				{Name: "postgres", Func: okCheck, Critical: true},
+				{Name: "redis", Func: okCheck, Critical: true},
+			},
+			expectedStatus: StatusOK,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name: "non-critical failure is degraded",
+			checks: []Check{
+				{Name: "postgres", Func: okCheck, Critical: true},
+				{Name: "kafka", Func: failCheck},
+			},
+			expectedStatus: StatusDegraded,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name: "critical failure is unavailable",
+			checks: []Check{
+				{Name: "postgres", Func: failCheck, Critical: true},
+				{Name: "kafka", Func: failCheck},
+			},
+			expectedStatus: StatusUnavailable,
+			expectedCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name: "check ignoring its context times out",
+			checks: []Check{
+				{Name: "postgres", Func: func(context.Context) error { time.Sleep(time.Second); return nil }, Critical: true},
+			},
+			expectedStatus: StatusUnavailable,
+			expectedCode:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(testHealthConfig(), logger.New("test"))
+			checker.Register(tt.checks...)
+
+			code, report := doReadiness(t, checker)
+			if code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, code)
+			}
+			if report.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, report.Status)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("Expected %d check results, got %d", len(tt.checks), len(report.Checks))
+			}
+			for _, check := range tt.checks {
+				result := report.Checks[check.Name]
+				if result.Critical != check.Critical {
+					t.Errorf("Expected %s critical=%v, got %v", check.Name, check.Critical, result.Critical)
+				}
+				if result.Status == StatusFail && result.Error == "" {
+					t.Errorf("Expected error detail for failed check %s", check.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessCache(t *testing.T) {
+	var calls atomic.Int32
+	slowCheck := func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	checker := NewChecker(testHealthConfig(), logger.New("test"))
+	checker.Register(Check{Name: "postgres", Func: slowCheck, Critical: true})
+
+	// Concurrent probes share one run, later probes use the cached result
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			checker.Check(context.Background())
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	checker.Check(context.Background())
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 check run, got %d", got)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	checker := NewChecker(testHealthConfig(), logger.New("test"))
+	checker.Register(Check{Name: "postgres", Func: okCheck, Critical: true})
+
+	checker.Drain()
+
+	code, report := doReadiness(t, checker)
+	if code != http.StatusServiceUnavailable || report.Status != StatusDraining {
+		t.Errorf("Expected 503 draining, got %d %s", code, report.Status)
+	}
+
+	// Liveness stays up while draining
+	rr := httptest.NewRecorder()
+	checker.Liveness(rr, httptest.NewRequest("GET", LivenessPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected liveness 200 while draining, got %d", rr.Code)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	cfg := testHealthConfig()
+	cfg.DrainDelay = 10 * time.Millisecond
+	checker := NewChecker(cfg, logger.New("test"))
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Start()
+	defer srv.Close()
+
+	if err := checker.Shutdown(context.Background(), srv.Config); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if !checker.Draining() {
+		t.Errorf("Expected checker to be draining after Shutdown")
+	}
+}
@@ -0,0 +1,311 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Config tunes the clients built by a Factory
+type Config struct {
+	Timeout          time.Duration // Per attempt; the request context bounds the whole call
+	MaxRetries       int           // Extra attempts for idempotent calls
+	RetryBackoff     time.Duration // First retry delay, doubled on each further retry
+	MaxRetryBackoff  time.Duration
+	BreakerThreshold int           // Consecutive failures that open the breaker, 0 disables it
+	BreakerCooldown  time.Duration // How long an open breaker fails fast
+}
+
+// DefaultConfig returns settings suited to calls between Mercuria services
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          5 * time.Second,
+		MaxRetries:       2,
+		RetryBackoff:     100 * time.Millisecond,
+		MaxRetryBackoff:  2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// StatusError is returned by DoJSON for non-2xx responses
+type StatusError struct {
+	Service    string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Service, e.StatusCode, e.Body)
+}
+
+// Factory builds clients for sibling services. Clients share one transport,
+// and so one connection pool and TLS config.
+type Factory struct {
+	transport *http.Transport
+	cfg       Config
+	logger    *logger.Logger
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewFactory creates a client factory. tlsConfig is typically
+// mtls.Config.ClientTLSConfig or mtls.CertManager.ClientTLSConfig; nil means
+// plain HTTP, as when mTLS is disabled.
+func NewFactory(tlsConfig *tls.Config, cfg Config, log *logger.Logger) *Factory {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = 32
+
+	return &Factory{
+		transport: transport,
+		cfg:       cfg,
+		logger:    log,
+		clients:   map[string]*Client{},
+	}
+}
+
+// Client returns the client for an upstream service, creating it on first use.
+// All callers of a service share its circuit breaker.
+func (f *Factory) Client(service, baseURL string) *Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.clients[service]; ok {
+		return c
+	}
+
+	c := &Client{
+		service: service,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Transport: f.transport},
+		cfg:     f.cfg,
+		breaker: newBreaker(f.cfg.BreakerThreshold, f.cfg.BreakerCooldown),
+		logger:  f.logger,
+	}
+	f.clients[service] = c
+	return c
+}
+
+// Client calls one upstream service
+type Client struct {
+	service string
+	baseURL string
+	http    *http.Client
+	cfg     Config
+	breaker *breaker
+	logger  *logger.Logger
+}
+
+// NewRequest creates a request for path on the upstream service
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return req, nil
+}
+
+// Do sends a request, propagating the request ID and trace context of its
+// context. Idempotent requests (by method, or carrying an Idempotency-Key) are
+// retried with backoff on network errors and 502/503/504 responses.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if retryable(req) {
+		attempts += c.cfg.MaxRetries
+	}
+
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			c.logger.Warnf("Retrying %s %s (attempt %d): %s", req.Method, req.URL.Path, attempt+1, describe(resp, err))
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err := sleep(req.Context(), c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := c.breaker.allow(); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.service, err)
+		}
+
+		resp, err = c.attempt(req)
+		c.breaker.record(err == nil && resp.StatusCode < 500)
+
+		if !shouldRetry(req.Context(), resp, err) {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s %s %s: %w", c.service, req.Method, req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// DoJSON sends in (if not nil) as JSON and decodes a 2xx response into out
+// (if not nil). Other statuses are returned as *StatusError.
+func (c *Client) DoJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &StatusError{Service: c.service, StatusCode: resp.StatusCode, Body: string(msg)}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", c.service, err)
+		}
+	}
+	return nil
+}
+
+// attempt sends one try of req under the per-attempt timeout
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if c.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), c.cfg.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Context())
+	}
+
+	r := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		r.Body = body
+	}
+
+	if id, ok := requestid.FromContext(ctx); ok && r.Header.Get(requestid.Header) == "" {
+		r.Header.Set(requestid.Header, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Keep the timeout running until the caller has read the body
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.RetryBackoff << (attempt - 1)
+	if d <= 0 || (c.cfg.MaxRetryBackoff > 0 && d > c.cfg.MaxRetryBackoff) {
+		d = c.cfg.MaxRetryBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Jitter spreads out retries of callers that failed together
+	return d/2 + rand.N(d/2+1)
+}
+
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false // The body cannot be sent twice
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false // The caller gave up
+	}
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func describe(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
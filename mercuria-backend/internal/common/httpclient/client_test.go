@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/devca"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestServer starts an mTLS server and returns a factory whose clients
+// trust it and present the "transaction" client certificate
+func newTestServer(t *testing.T, cfg Config, handler http.HandlerFunc) (*httptest.Server, *Factory) {
+	t.Helper()
+
+	ca, err := devca.New(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	serverCert, err := ca.Issue(devca.IssueOptions{Service: "wallet", Usage: devca.UsageServer})
+	if err != nil {
+		t.Fatalf("Failed to issue server certificate: %v", err)
+	}
+	clientCert, err := ca.Issue(devca.IssueOptions{Service: "transaction", Usage: devca.UsageClient})
+	if err != nil {
+		t.Fatalf("Failed to issue client certificate: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLSCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.Pool(),
+		MinVersion:   tls.VersionTLS13,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{clientCert.TLSCertificate()},
+		RootCAs:      ca.Pool(),
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	return server, NewFactory(tlsConfig, cfg, logger.New("test"))
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.RetryBackoff = time.Millisecond
+	cfg.MaxRetryBackoff = 5 * time.Millisecond
+	return cfg
+}
+
+func TestClientMTLSAndPropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var caller, gotRequestID, gotTraceparent string
+	server, factory := newTestServer(t, testConfig(), func(w http.ResponseWriter, r *http.Request) {
+		caller = r.TLS.PeerCertificates[0].Subject.CommonName
+		gotRequestID = r.Header.Get(requestid.Header)
+		gotTraceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"balance":"100.00"}`))
+	})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ctx = requestid.NewContext(ctx, "req-123")
+
+	var out struct {
+		Balance string `json:"balance"`
+	}
+	client := factory.Client("wallet", server.URL)
+	if err := client.DoJSON(ctx, http.MethodGet, "/internal/v1/wallets/w1", nil, &out); err != nil {
+		t.Fatalf("DoJSON failed: %v", err)
+	}
+
+	if out.Balance != "100.00" {
+		t.Errorf("Expected balance 100.00, got %q", out.Balance)
+	}
+	if caller != "transaction" {
+		t.Errorf("Expected client certificate for transaction, got %q", caller)
+	}
+	if gotRequestID != "req-123" {
+		t.Errorf("Expected request ID req-123, got %q", gotRequestID)
+	}
+	if !strings.Contains(gotTraceparent, traceID.String()) {
+		t.Errorf("Expected traceparent with trace ID, got %q", gotTraceparent)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		idempotencyKey   string
+		expectedAttempts int32
+		expectedStatus   int
+	}{
+		{"GET is retried", http.MethodGet, "", 3, http.StatusOK},
+		{"POST is not retried", http.MethodPost, "", 1, http.StatusServiceUnavailable},
+		{"POST with idempotency key is retried", http.MethodPost, "key-1", 3, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server, factory := newTestServer(t, testConfig(), func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			client := factory.Client("wallet", server.URL)
+			req, err := client.NewRequest(context.Background(), tt.method, "/test", strings.NewReader(`{"amount":"10.00"}`))
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			if tt.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tt.idempotencyKey)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if attempts.Load() != tt.expectedAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedAttempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.Timeout = 50 * time.Millisecond
+	cfg.MaxRetries = 0
+
+	server, factory := newTestServer(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	err := factory.Client("wallet", server.URL).DoJSON(context.Background(), http.MethodGet, "/slow", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 2
+	cfg.BreakerCooldown = 50 * time.Millisecond
+
+	var attempts atomic.Int32
+	var healthy atomic.Bool
+	server, factory := newTestServer(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	client := factory.Client("wallet", server.URL)
+	call := func() error {
+		return client.DoJSON(context.Background(), http.MethodGet, "/test", nil, nil)
+	}
+
+	for i := 0; i < 2; i++ {
+		var statusErr *StatusError
+		if err := call(); !errors.As(err, &statusErr) {
+			t.Fatalf("Expected status error, got %v", err)
+		}
+	}
+
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected open circuit, got %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected open circuit to skip the upstream, got %d attempts", attempts.Load())
+	}
+
+	// After the cooldown a successful probe closes the circuit
+	healthy.Store(true)
+	time.Sleep(cfg.BreakerCooldown)
+
+	if err := call(); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if err := call(); err != nil {
+		t.Errorf("Expected closed circuit, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultMinRefreshInterval limits refetches triggered by unknown kids, so
+// forged tokens cannot make us hammer the auth service
+const defaultMinRefreshInterval = 30 * time.Second
+
+type cachedKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// Cache verifies tokens against a JWK Set fetched from an HTTP(S) URL or read
+// from a local file. Keys are refreshed after ttl, or early when a token
+// carries a kid we have not seen, which is how rotated keys get picked up.
+type Cache struct {
+	source     string
+	ttl        time.Duration
+	minRefresh time.Duration
+	client     *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]cachedKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func NewCache(source string, ttl time.Duration) *Cache {
+	return &Cache{
+		source:     source,
+		ttl:        ttl,
+		minRefresh: defaultMinRefreshInterval,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		keys:       map[string]cachedKey{},
+	}
+}
+
+// Refresh fetches the JWK Set and replaces the cached keys
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	data, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]cachedKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue // Skip keys we do not understand
+		}
+		alg := jwk.Alg
+		if alg == "" {
+			if alg, err = algForKey(pub); err != nil {
+				continue
+			}
+		}
+		keys[jwk.Kid] = cachedKey{alg: alg, key: pub}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Cache) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(c.source, "http://") && !strings.HasPrefix(c.source, "https://") {
+		data, err := os.ReadFile(c.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return data, nil
+}
+
+func (c *Cache) lookup(kid string) (cachedKey, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key, ok := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > c.ttl
+	return key, ok, stale
+}
+
+func (c *Cache) canRefresh() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.lastAttempt) >= c.minRefresh
+}
+
+// Keyfunc returns the public key for a token, for use with jwt.Parse
+func (c *Cache) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no key id")
+	}
+
+	key, ok, stale := c.lookup(kid)
+	if (!ok || stale) && c.canRefresh() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// On failure keep using what we have; an unknown kid still fails below
+		if err := c.Refresh(ctx); err == nil {
+			key, ok, _ = c.lookup(kid)
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+	}
+
+	return key.key, nil
+}
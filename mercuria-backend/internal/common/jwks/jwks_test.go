@@ -0,0 +1,275 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user-123",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			ks, err := NewKeySet(alg, 0)
+			if err != nil {
+				t.Fatalf("NewKeySet failed: %v", err)
+			}
+
+			jwk := ks.JWKS().Keys[0]
+			if jwk.Alg != alg || jwk.Kid == "" {
+				t.Errorf("Unexpected JWK header fields: %+v", jwk)
+			}
+
+			pub, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatalf("PublicKey failed: %v", err)
+			}
+
+			again, err := NewJWK(pub, alg)
+			if err != nil {
+				t.Fatalf("NewJWK failed: %v", err)
+			}
+			if again.Kid != jwk.Kid {
+				t.Errorf("Expected stable thumbprint %s, got %s", jwk.Kid, again.Kid)
+			}
+		})
+	}
+}
+
+// fakeClock is a settable clock for KeySet activation
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestCacheVerifiesRotatedKeys(t *testing.T) {
+	ks, err := NewKeySet(AlgEdDSA, 1)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	clock := &fakeClock{now: time.Now()}
+	ks.now = clock.Now
+
+	server := httptest.NewServer(ks.Handler())
+	defer server.Close()
+
+	cache := NewCache(server.URL+WellKnownPath, time.Minute)
+	cache.minRefresh = 0
+
+	oldToken, err := ks.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if _, err := jwt.Parse(oldToken, cache.Keyfunc); err != nil {
+		t.Fatalf("Expected token to verify: %v", err)
+	}
+
+	if err := ks.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	clock.now = clock.now.Add(ActivationDelay)
+
+	// New kid triggers a refresh; the previous key is still published
+	newToken, err := ks.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if _, err := jwt.Parse(newToken, cache.Keyfunc); err != nil {
+		t.Errorf("Expected rotated token to verify: %v", err)
+	}
+	if _, err := jwt.Parse(oldToken, cache.Keyfunc); err != nil {
+		t.Errorf("Expected token of retained key to verify: %v", err)
+	}
+
+	// A second rotation retires the original key once its successor signs
+	if err := ks.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := jwt.Parse(oldToken, cache.Keyfunc); err != nil {
+		t.Errorf("Expected token of retained key to verify until its successor signs: %v", err)
+	}
+	clock.now = clock.now.Add(ActivationDelay)
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := jwt.Parse(oldToken, cache.Keyfunc); err == nil {
+		t.Error("Expected token of retired key to be rejected")
+	}
+}
+
+func TestRotateAgainstFreshCache(t *testing.T) {
+	ks, err := NewKeySet(AlgEdDSA, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+	clock := &fakeClock{now: time.Now()}
+	ks.now = clock.Now
+
+	server := httptest.NewServer(ks.Handler())
+	defer server.Close()
+
+	// The verifier has just refreshed, so it will not refetch for a while
+	cache := NewCache(server.URL+WellKnownPath, time.Hour)
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	oldKid := ks.JWKS().Keys[0].Kid
+
+	if err := ks.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	set := ks.JWKS()
+	if len(set.Keys) != 2 || set.Keys[1].Kid != oldKid {
+		t.Fatalf("Expected the new key to be published next to the signing key, got %d keys", len(set.Keys))
+	}
+
+	// Until the new key activates, tokens carry the key the cache knows
+	signed, err := ks.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	token, err := jwt.Parse(signed, cache.Keyfunc)
+	if err != nil {
+		t.Fatalf("Expected token signed straight after rotation to verify: %v", err)
+	}
+	if token.Header["kid"] != oldKid {
+		t.Errorf("Expected the previous key %s to sign, got %v", oldKid, token.Header["kid"])
+	}
+
+	// By the time the new key signs, the cache may refetch for its kid
+	clock.now = clock.now.Add(ActivationDelay)
+	cache.mu.Lock()
+	cache.lastAttempt = time.Now().Add(-cache.minRefresh)
+	cache.mu.Unlock()
+
+	signed, err = ks.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	token, err = jwt.Parse(signed, cache.Keyfunc)
+	if err != nil {
+		t.Fatalf("Expected token of the activated key to verify: %v", err)
+	}
+	if token.Header["kid"] != set.Keys[0].Kid {
+		t.Errorf("Expected the new key %s to sign, got %v", set.Keys[0].Kid, token.Header["kid"])
+	}
+}
+
+func TestCacheRejectsAlgorithmMismatch(t *testing.T) {
+	ks, err := NewKeySet(AlgRS256, 0)
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data, _ := json.Marshal(ks.JWKS())
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	cache := NewCache(path, time.Minute)
+
+	// HMAC token using the public key's kid must not be accepted
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newTestClaims())
+	token.Header["kid"] = ks.JWKS().Keys[0].Kid
+	signed, _ := token.SignedString([]byte("secret"))
+
+	if _, err := jwt.Parse(signed, cache.Keyfunc); err == nil {
+		t.Error("Expected HS256 token to be rejected")
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"2025-01-01.pem", "2025-06-01.pem"} {
+		writeTestKey(t, filepath.Join(dir, name))
+	}
+
+	ks, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet failed: %v", err)
+	}
+	if ks.Algorithm() != AlgEdDSA {
+		t.Errorf("Expected EdDSA, got %s", ks.Algorithm())
+	}
+
+	set := ks.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("Expected 2 published keys, got %d", len(set.Keys))
+	}
+
+	signed, err := ks.Sign(newTestClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	// The newest file signs, and it is listed first
+	token, err := jwt.Parse(signed, ks.Keyfunc)
+	if err != nil {
+		t.Fatalf("Expected token to verify: %v", err)
+	}
+	if token.Header["kid"] != set.Keys[0].Kid {
+		t.Errorf("Expected newest key %s to sign, got %v", set.Keys[0].Kid, token.Header["kid"])
+	}
+
+	// A key added to a running set is published before it signs
+	clock := &fakeClock{now: time.Now()}
+	ks.now = clock.Now
+	writeTestKey(t, filepath.Join(dir, "2025-12-01.pem"))
+	if err := ks.Reload(dir); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	added := ks.JWKS().Keys[0].Kid
+	if signed, err = ks.Sign(newTestClaims()); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if token, _ := jwt.Parse(signed, ks.Keyfunc); token.Header["kid"] != set.Keys[0].Kid {
+		t.Errorf("Expected %s to sign until the new key activates, got %v", set.Keys[0].Kid, token.Header["kid"])
+	}
+	clock.now = clock.now.Add(ActivationDelay)
+	if signed, err = ks.Sign(newTestClaims()); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if token, _ := jwt.Parse(signed, ks.Keyfunc); token.Header["kid"] != added {
+		t.Errorf("Expected the added key %s to sign, got %v", added, token.Header["kid"])
+	}
+}
+
+func writeTestKey(t *testing.T, path string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
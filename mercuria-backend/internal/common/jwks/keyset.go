@@ -0,0 +1,306 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// WellKnownPath is where the auth service publishes its JWK Set
+const WellKnownPath = "/.well-known/jwks.json"
+
+// ActivationDelay is how long a new key is published before it signs. A Cache
+// refetches the JWK Set for an unknown kid at most every
+// defaultMinRefreshInterval, and HTTP caches may serve the set for
+// jwksMaxAge, so a verifier that refreshed just before the rotation would
+// reject tokens signed by the new key any sooner.
+const ActivationDelay = defaultMinRefreshInterval + jwksMaxAge
+
+// jwksMaxAge is how long HTTP caches may keep the served JWK Set
+const jwksMaxAge = 30 * time.Second
+
+type signingKey struct {
+	signer   crypto.Signer
+	jwk      JWK
+	activeAt time.Time // Signs from then on; zero for keys active from the start
+}
+
+// KeySet holds the auth service's signing keys. The newest active key signs;
+// newer keys are published ahead of signing, and older keys stay published
+// so tokens they signed verify until they expire.
+type KeySet struct {
+	mu              sync.RWMutex
+	alg             string
+	retain          int           // Number of previous keys kept after a rotation
+	activationDelay time.Duration // How long new keys are published before they sign
+	keys            []*signingKey // Oldest first
+	now             func() time.Time
+}
+
+// NewKeySet creates a key set with a freshly generated key, which signs
+// straight away. Generated keys live in memory only, so this suits tests and
+// single-instance setups; replicated auth services should share keys with
+// LoadKeySet.
+func NewKeySet(alg string, retain int) (*KeySet, error) {
+	ks := &KeySet{alg: alg, retain: retain, activationDelay: ActivationDelay, now: time.Now}
+	if err := ks.Rotate(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads PEM-encoded private keys (PKCS#8, or PKCS#1 for RSA) from
+// dir. Files are ordered by name and the last one signs, so rotating means
+// dropping a new file that sorts last (e.g. 2025-06-01.pem) and reloading;
+// the new key signs ActivationDelay after the reload.
+func LoadKeySet(dir string) (*KeySet, error) {
+	ks := &KeySet{activationDelay: ActivationDelay, now: time.Now}
+	if err := ks.Reload(dir); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload replaces the keys with the ones currently in dir
+func (ks *KeySet) Reload(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no signing keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	var keys []*signingKey
+	alg := ""
+	for _, path := range paths {
+		signer, err := readPrivateKey(path)
+		if err != nil {
+			return err
+		}
+
+		keyAlg, err := algForKey(signer.Public())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if alg != "" && keyAlg != alg {
+			return fmt.Errorf("signing keys mix %s and %s", alg, keyAlg)
+		}
+		alg = keyAlg
+
+		key, err := newSigningKey(signer, keyAlg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// Keys new to a running set wait to sign like rotated ones, unless none
+	// of the previous keys remain to sign in the meantime
+	known := make(map[string]time.Time, len(ks.keys))
+	for _, key := range ks.keys {
+		known[key.jwk.Kid] = key.activeAt
+	}
+	activeAt := ks.now().Add(ks.activationDelay)
+	retained := false
+	for _, key := range keys {
+		if at, ok := known[key.jwk.Kid]; ok {
+			key.activeAt = at
+			retained = true
+		} else {
+			key.activeAt = activeAt
+		}
+	}
+	if !retained {
+		for _, key := range keys {
+			key.activeAt = time.Time{}
+		}
+	}
+
+	ks.alg = alg
+	ks.keys = keys
+	ks.retain = len(keys) - 1
+	return nil
+}
+
+// Rotate generates a new key, which is published straight away and signs
+// after the activation delay. Keys beyond the retention limit, which counts
+// keys older than the one signing, are retired as newer keys activate.
+func (ks *KeySet) Rotate() error {
+	signer, err := generateKey(ks.alg)
+	if err != nil {
+		return err
+	}
+
+	key, err := newSigningKey(signer, ks.alg)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// The first key has no predecessor to sign while it is published
+	if len(ks.keys) > 0 {
+		key.activeAt = ks.now().Add(ks.activationDelay)
+	}
+	ks.keys = append(ks.keys, key)
+	ks.keys = ks.keys[ks.firstPublished():]
+
+	return nil
+}
+
+// firstPublished returns the index of the oldest key still published: the
+// retained keys before the current one. Callers must hold ks.mu.
+func (ks *KeySet) firstPublished() int {
+	return max(ks.currentIndex()-ks.retain, 0)
+}
+
+// currentIndex returns the index of the newest key that may sign. Callers
+// must hold ks.mu.
+func (ks *KeySet) currentIndex() int {
+	now := ks.now()
+	for i := len(ks.keys) - 1; i > 0; i-- {
+		if !ks.keys[i].activeAt.After(now) {
+			return i
+		}
+	}
+	return 0
+}
+
+// Algorithm returns the signing algorithm, RS256 or EdDSA
+func (ks *KeySet) Algorithm() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.alg
+}
+
+// Sign signs claims with the current key, setting the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	current := ks.keys[ks.currentIndex()]
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(current.jwk.Alg), claims)
+	token.Header["kid"] = current.jwk.Kid
+
+	return token.SignedString(current.signer)
+}
+
+// JWKS returns the public keys as a JWK Set
+func (ks *KeySet) JWKS() Set {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	first := ks.firstPublished()
+	set := Set{Keys: make([]JWK, 0, len(ks.keys)-first)}
+	for i := len(ks.keys) - 1; i >= first; i-- {
+		set.Keys = append(set.Keys, ks.keys[i].jwk)
+	}
+	return set
+}
+
+// Keyfunc verifies tokens against the set's own keys, for the auth service
+// itself. Other services should use a Cache.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys[ks.firstPublished():] {
+		if key.jwk.Kid == kid {
+			if token.Method.Alg() != key.jwk.Alg {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+			}
+			return key.signer.Public(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key id: %q", kid)
+}
+
+// Handler serves the JWK Set. Mount it at WellKnownPath.
+func (ks *KeySet) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
+
+func newSigningKey(signer crypto.Signer, alg string) (*signingKey, error) {
+	jwk, err := NewJWK(signer.Public(), alg)
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{signer: signer, jwk: jwk}, nil
+}
+
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return key, nil
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	if strings.Contains(block.Type, "RSA") {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to parse RSA key: %w", path, err)
+		}
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse private key: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+	}
+	return signer, nil
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/kmassidik/mercuria/internal/common/config"
 	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Consumer struct {
@@ -16,7 +22,9 @@ type Consumer struct {
 	logger *logger.Logger
 }
 
-// EventHandler is a function that processes Kafka events
+// EventHandler is a function that processes Kafka events. ctx carries the
+// request ID of the request that caused the event, if it was published with
+// one, and the consumer span, which continues the producer's trace.
 type EventHandler func(ctx context.Context, key []byte, value []byte) error
 
 // NewConsumer creates a new Kafka consumer
@@ -61,11 +69,7 @@ func (c *Consumer) Consume(ctx context.Context, handler EventHandler) error {
 				continue
 			}
 
-			c.logger.Debugf("Received message from topic %s: key=%s", msg.Topic, string(msg.Key))
-
-			// Process message
-			if err := handler(ctx, msg.Key, msg.Value); err != nil {
-				c.logger.Errorf("Failed to process message: %v", err)
+			if err := c.process(ctx, msg, handler); err != nil {
 				// Don't commit on error - message will be retried
 				continue
 			}
@@ -78,6 +82,41 @@ func (c *Consumer) Consume(ctx context.Context, handler EventHandler) error {
 	}
 }
 
+// process runs handler for msg under a consumer span
+func (c *Consumer) process(ctx context.Context, msg kafka.Message, handler EventHandler) error {
+	msgCtx, span := tracing.Tracer().Start(contextFromHeaders(ctx, msg.Headers), "process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingConsumerGroupName(c.reader.Config().GroupID),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+			semconv.MessagingKafkaOffset(int(msg.Offset)),
+		),
+	)
+	defer span.End()
+
+	c.logger.DebugContext(msgCtx, "Received message", "topic", msg.Topic, "key", string(msg.Key))
+
+	// HighWaterMark is the offset of the next message to be written
+	metrics.KafkaConsumerLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(msg.HighWaterMark - msg.Offset - 1))
+	metrics.KafkaMessagesConsumed.WithLabelValues(msg.Topic).Inc()
+
+	start := time.Now()
+	err := handler(msgCtx, msg.Key, msg.Value)
+	metrics.KafkaHandlerDuration.WithLabelValues(msg.Topic).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metrics.KafkaHandlerErrors.WithLabelValues(msg.Topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "handler failed")
+		c.logger.ErrorContext(msgCtx, "Failed to process message", "topic", msg.Topic, "error", err)
+		return err
+	}
+	return nil
+}
+
 // Close closes the consumer
 func (c *Consumer) Close() error {
 	c.logger.Info("Closing Kafka consumer")
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// RequestIDHeader carries the ID of the request that caused an event
+const RequestIDHeader = requestid.Header
+
+// headersFromContext returns the correlation headers for a message
+// published under ctx: the request ID and the W3C trace context
+func headersFromContext(ctx context.Context) []kafka.Header {
+	var headers []kafka.Header
+	if id, ok := requestid.FromContext(ctx); ok {
+		headers = append(headers, kafka.Header{Key: RequestIDHeader, Value: []byte(id)})
+	}
+
+	carrier := headerCarrier{headers: &headers}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return headers
+}
+
+// contextFromHeaders restores the correlation headers of a consumed message
+// into ctx
+func contextFromHeaders(ctx context.Context, headers []kafka.Header) context.Context {
+	for _, h := range headers {
+		if h.Key == RequestIDHeader && len(h.Value) > 0 {
+			ctx = requestid.NewContext(ctx, string(h.Value))
+		}
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &headers})
+}
+
+// headerCarrier adapts message headers to the propagation.TextMapCarrier interface
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestIDHeaders(t *testing.T) {
+	if headers := headersFromContext(context.Background()); len(headers) != 0 {
+		t.Errorf("Expected no headers without a request ID, got %v", headers)
+	}
+
+	headers := headersFromContext(requestid.NewContext(context.Background(), "req-123"))
+	if len(headers) != 1 || headers[0].Key != RequestIDHeader {
+		t.Fatalf("Expected request ID header, got %v", headers)
+	}
+
+	ctx := contextFromHeaders(context.Background(), headers)
+	if id, ok := requestid.FromContext(ctx); !ok || id != "req-123" {
+		t.Errorf("Expected request ID req-123, got %q", id)
+	}
+}
+
+func TestTraceContextHeaders(t *testing.T) {
+	exporter := tracing.SetupInMemory("test")
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "request")
+	headers := headersFromContext(ctx)
+	span.End()
+
+	carrier := headerCarrier{headers: &headers}
+	if carrier.Get("traceparent") == "" {
+		t.Fatalf("Expected traceparent header, got %v", headers)
+	}
+
+	msgCtx := contextFromHeaders(context.Background(), headers)
+	if got := trace.SpanContextFromContext(msgCtx); got.TraceID() != span.SpanContext().TraceID() || !got.IsRemote() {
+		t.Errorf("Expected remote span context in trace %s, got %v", span.SpanContext().TraceID(), got)
+	}
+
+	if len(exporter.GetSpans()) != 1 {
+		t.Errorf("Expected 1 span, got %d", len(exporter.GetSpans()))
+	}
+}
@@ -7,7 +7,12 @@ import (
 
 	"github.com/kmassidik/mercuria/internal/common/config"
 	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Producer struct {
@@ -33,25 +38,46 @@ func NewProducer(cfg config.KafkaConfig, log *logger.Logger) *Producer {
 	}
 }
 
-// PublishEvent publishes an event to a Kafka topic
-func (p *Producer) PublishEvent(ctx context.Context, topic string, key string, event interface{}) error {
+// PublishEvent publishes an event to a Kafka topic. The request ID and trace
+// context in ctx are sent as headers so consumers can correlate the event.
+func (p *Producer) PublishEvent(ctx context.Context, topic string, key string, event interface{}) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "send "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingKafkaMessageKey(key),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "publish failed")
+		}
+		span.End()
+	}()
+
 	eventBytes, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
 	msg := kafka.Message{
-		Topic: topic,
-		Key:   []byte(key),
-		Value: eventBytes,
+		Topic:   topic,
+		Key:     []byte(key),
+		Value:   eventBytes,
+		Headers: headersFromContext(ctx),
 	}
 
 	if err := p.writer.WriteMessages(ctx, msg); err != nil {
-		p.logger.Errorf("Failed to publish event to topic %s: %v", topic, err)
+		metrics.KafkaMessagesPublished.WithLabelValues(topic, "error").Inc()
+		p.logger.ErrorContext(ctx, "Failed to publish event", "topic", topic, "error", err)
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
+	metrics.KafkaMessagesPublished.WithLabelValues(topic, "success").Inc()
 
-	p.logger.Debugf("Event published to topic %s with key %s", topic, key)
+	p.logger.DebugContext(ctx, "Event published", "topic", topic, "key", key)
 	return nil
 }
 
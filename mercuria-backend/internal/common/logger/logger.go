@@ -1,68 +1,232 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text" // For reading logs in a terminal during development
+)
+
+// Options configures a Logger. The zero value logs JSON at info level to stdout.
+type Options struct {
+	Level  slog.Level
+	Format string
+	Output io.Writer
+}
+
+// ContextField extracts a log field from a request context
+type ContextField func(ctx context.Context) (string, bool)
 
+var (
+	contextFieldsMu sync.RWMutex
+	contextFields   = map[string]ContextField{}
+)
+
+// RegisterContextField adds a field that the *Context methods attach from
+// the context. Packages that logger cannot import register their fields in
+// init, e.g. middleware registers user_id.
+func RegisterContextField(key string, field ContextField) {
+	contextFieldsMu.Lock()
+	defer contextFieldsMu.Unlock()
+	contextFields[key] = field
+}
+
+// Logger writes structured logs through log/slog. Every record carries the
+// service name; records logged with a context also carry request_id, trace_id
+// and any registered context fields.
 type Logger struct {
-	info *log.Logger
-	warn *log.Logger
-	error *log.Logger
-	debug *log.Logger
+	handler slog.Handler
+	level   *slog.LevelVar
 }
 
+// New creates a logger configured by LOG_LEVEL (debug, info, warn, error;
+// default info) and LOG_FORMAT (json or text; default json)
 func New(serviceName string) *Logger {
-	prefix := "[" + serviceName + "] "
+	opts := Options{Format: os.Getenv("LOG_FORMAT")}
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if err := opts.Level.UnmarshalText([]byte(level)); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid LOG_LEVEL %q, using info\n", level)
+		}
+	}
+	return NewWithOptions(serviceName, opts)
+}
+
+// NewWithOptions creates a logger with explicit options
+func NewWithOptions(serviceName string, opts Options) *Logger {
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	level := &slog.LevelVar{}
+	level.Set(opts.Level)
 
-	return &Logger{
-		info: log.New(os.Stdout, prefix+"INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		warn: log.New(os.Stdout, prefix+"WARN: ", log.Ldate|log.Ltime|log.Lshortfile),
-		error: log.New(os.Stdout, prefix+"ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
-		debug: log.New(os.Stdout, prefix+"DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile),
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: shortSource,
 	}
+
+	var handler slog.Handler
+	if opts.Format == FormatText {
+		handler = slog.NewTextHandler(output, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	}
+
+	handler = contextHandler{handler.WithAttrs([]slog.Attr{slog.String("service", serviceName)})}
+	return &Logger{handler: handler, level: level}
+}
+
+// SetLevel changes the minimum level of the logger and all its children
+func (l *Logger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
+// With returns a child logger that adds key=value to every record
+func (l *Logger) With(key string, value interface{}) *Logger {
+	return &Logger{handler: l.handler.WithAttrs([]slog.Attr{slog.Any(key, value)}), level: l.level}
+}
+
+// Slog returns the logger as a *slog.Logger, for libraries that take one
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(l.handler)
 }
 
 func (l *Logger) Info(v ...interface{}) {
-	l.info.Println(v...)
+	l.log(context.Background(), slog.LevelInfo, sprintln(v...))
 }
 
 func (l *Logger) Infof(format string, v ...interface{}) {
-	l.info.Printf(format, v...)
+	l.log(context.Background(), slog.LevelInfo, fmt.Sprintf(format, v...))
 }
 
 func (l *Logger) Warn(v ...interface{}) {
-	l.warn.Println(v...)
+	l.log(context.Background(), slog.LevelWarn, sprintln(v...))
 }
 
 func (l *Logger) Warnf(format string, v ...interface{}) {
-	l.warn.Printf(format, v...)
+	l.log(context.Background(), slog.LevelWarn, fmt.Sprintf(format, v...))
 }
 
 func (l *Logger) Error(v ...interface{}) {
-	l.error.Println(v...)
+	l.log(context.Background(), slog.LevelError, sprintln(v...))
 }
 
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.error.Printf(format, v...)
+	l.log(context.Background(), slog.LevelError, fmt.Sprintf(format, v...))
 }
 
 func (l *Logger) Debug(v ...interface{}) {
-	l.debug.Println(v...)
+	l.log(context.Background(), slog.LevelDebug, sprintln(v...))
 }
 
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	l.debug.Printf(format, v...)
+	l.log(context.Background(), slog.LevelDebug, fmt.Sprintf(format, v...))
 }
 
 // Fatal logs and exits
 func (l *Logger) Fatal(v ...interface{}) {
-	l.error.Fatal(v...)
+	l.log(context.Background(), slog.LevelError, sprintln(v...))
+	os.Exit(1)
 }
 
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.error.Fatalf(format, v...)
+	l.log(context.Background(), slog.LevelError, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// InfoContext logs msg with alternating key, value args and the context fields
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, slog.LevelInfo, msg, args...)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, slog.LevelWarn, msg, args...)
 }
 
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, slog.LevelError, msg, args...)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, slog.LevelDebug, msg, args...)
+}
+
+// log records the caller of the exported method as the source
+func (l *Logger) log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.Add(args...)
+	l.handler.Handle(ctx, record)
+}
+
+// contextHandler adds request_id, trace_id, span_id and the registered
+// context fields to records logged with a context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := requestid.FromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+
+	contextFieldsMu.RLock()
+	for key, field := range contextFields {
+		if value, ok := field(ctx); ok {
+			record.AddAttrs(slog.String(key, value))
+		}
+	}
+	contextFieldsMu.RUnlock()
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
 
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// shortSource logs the source as file:line, like log.Lshortfile
+func shortSource(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.SourceKey && len(groups) == 0 {
+		if src, ok := attr.Value.Any().(*slog.Source); ok {
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+		}
+	}
+	return attr
+}
+
+// sprintln formats like log.Println, without the trailing newline
+func sprintln(v ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
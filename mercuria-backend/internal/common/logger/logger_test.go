@@ -2,24 +2,118 @@ package logger
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"strings"
 	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func TestLogger(t *testing.T)  {
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON log line, got %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
 	var buf bytes.Buffer
 
-	logger := New("test-service")
-	logger.info.SetOutput(&buf)
+	logger := NewWithOptions("test-service", Options{Output: &buf})
 
 	logger.Info("test message")
+	logger.Errorf("failed after %d attempts", 3)
+
+	records := decodeLines(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	if records[0]["msg"] != "test message" || records[0]["level"] != "INFO" {
+		t.Errorf("Unexpected record %v", records[0])
+	}
+	if records[0]["service"] != "test-service" {
+		t.Errorf("Expected service name, got %v", records[0]["service"])
+	}
+	if source, _ := records[0]["source"].(string); !strings.HasPrefix(source, "logger_test.go:") {
+		t.Errorf("Expected caller as source, got %v", records[0]["source"])
+	}
+	if records[1]["msg"] != "failed after 3 attempts" || records[1]["level"] != "ERROR" {
+		t.Errorf("Unexpected record %v", records[1])
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := NewWithOptions("test-service", Options{Level: slog.LevelWarn, Output: &buf})
+	logger.Debug("hidden")
+	logger.Info("hidden")
+	logger.Warn("shown")
 
-	output := buf.String()
-	if !strings.Contains(output, "test message") {
-		t.Errorf("Expected log to contain 'test message', got: %s", output)
+	if records := decodeLines(t, &buf); len(records) != 1 || records[0]["msg"] != "shown" {
+		t.Errorf("Expected only the warning, got %v", records)
 	}
 
-	if !strings.Contains(output, "[test-service]"){
-		t.Errorf("Expected log to contain service name, got: %s", output)
+	buf.Reset()
+	logger.SetLevel(slog.LevelDebug)
+	logger.Debug("now shown")
+	if records := decodeLines(t, &buf); len(records) != 1 {
+		t.Errorf("Expected debug record after SetLevel, got %v", records)
 	}
-}
\ No newline at end of file
+}
+
+func TestLoggerWithAndContext(t *testing.T) {
+	var buf bytes.Buffer
+
+	RegisterContextField("test_field", func(ctx context.Context) (string, bool) {
+		v, ok := ctx.Value(testFieldKey{}).(string)
+		return v, ok
+	})
+
+	logger := NewWithOptions("test-service", Options{Output: &buf}).With("component", "outbox")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = requestid.NewContext(ctx, "req-123")
+	ctx = context.WithValue(ctx, testFieldKey{}, "value")
+
+	logger.InfoContext(ctx, "published", "events", 5)
+
+	records := decodeLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+
+	expected := map[string]interface{}{
+		"component":  "outbox",
+		"events":     float64(5),
+		"request_id": "req-123",
+		"trace_id":   traceID.String(),
+		"test_field": "value",
+		"service":    "test-service",
+	}
+	for key, value := range expected {
+		if records[0][key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, records[0][key])
+		}
+	}
+}
+
+type testFieldKey struct{}
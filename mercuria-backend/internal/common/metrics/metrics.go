@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is where services expose metrics for Prometheus to scrape
+const Path = "/metrics"
+
+// Registry holds the metrics of this process: the Go runtime and process
+// collectors, and the collectors of the common packages
+var Registry = prometheus.NewRegistry()
+
+// Register adds collectors to Registry. A collector with the same metrics as
+// one already registered replaces it, so reconnecting a client reports the
+// new client's pool.
+func Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		err := Registry.Register(c)
+
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			Registry.Unregister(already.ExistingCollector)
+			err = Registry.Register(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// HTTP metrics, recorded by middleware.Metrics. route is the matched ServeMux
+// pattern, so path parameters do not create a series per ID.
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method, route and status code.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+)
+
+// Outbox metrics, recorded by the outbox publisher
+var (
+	OutboxPendingEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_pending_events",
+		Help: "Outbox events waiting to be published.",
+	})
+
+	OutboxPublishLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbox_publish_latency_seconds",
+		Help:    "Time from saving an outbox event to publishing it to Kafka, by topic.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12), // 50ms to ~100s
+	}, []string{"topic"})
+
+	OutboxPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Failed attempts to publish outbox events, by topic.",
+	}, []string{"topic"})
+
+	OutboxEventsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_failed_total",
+		Help: "Outbox events given up on after the maximum attempts, by topic.",
+	}, []string{"topic"})
+)
+
+// Kafka metrics, recorded by kafka.Producer and kafka.Consumer
+var (
+	KafkaMessagesPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_producer_messages_total",
+		Help: "Messages published, by topic and result (success or error).",
+	}, []string{"topic", "result"})
+
+	KafkaMessagesConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_messages_total",
+		Help: "Messages handled by consumers, by topic.",
+	}, []string{"topic"})
+
+	KafkaHandlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_handler_errors_total",
+		Help: "Messages whose handler returned an error, by topic.",
+	}, []string{"topic"})
+
+	KafkaHandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consumer_handler_duration_seconds",
+		Help:    "Time spent in consumer handlers, by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages behind the partition's high watermark at the last fetch, by topic and partition.",
+	}, []string{"topic", "partition"})
+)
+
+// Redis lock metrics, recorded by redis.Client.AcquireLock
+var RedisLockAcquisitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "redis_lock_acquisitions_total",
+	Help: "Lock acquisition attempts, by result (acquired, contended or error).",
+}, []string{"result"})
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		OutboxPendingEvents,
+		OutboxPublishLatency,
+		OutboxPublishFailures,
+		OutboxEventsFailed,
+		KafkaMessagesPublished,
+		KafkaMessagesConsumed,
+		KafkaHandlerErrors,
+		KafkaHandlerDuration,
+		KafkaConsumerLag,
+		RedisLockAcquisitions,
+	)
+}
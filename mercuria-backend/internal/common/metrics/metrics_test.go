@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandler(t *testing.T) {
+	HTTPRequestsTotal.WithLabelValues("GET", "GET /api/v1/wallets/{id}", "200").Inc()
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", Path, nil))
+
+	body, _ := io.ReadAll(rr.Body)
+	for _, expected := range []string{
+		`http_requests_total{method="GET",route="GET /api/v1/wallets/{id}",status="200"} 1`,
+		"go_goroutines",
+		"outbox_pending_events",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected %q in metrics output", expected)
+		}
+	}
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	newGauge := func(value float64) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_pool_connections", Help: "Test gauge."})
+		g.Set(value)
+		return g
+	}
+
+	if err := Register(newGauge(1)); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if err := Register(newGauge(2)); err != nil {
+		t.Fatalf("Expected re-registering to succeed, got %v", err)
+	}
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "test_pool_connections" {
+			if got := family.GetMetric()[0].GetGauge().GetValue(); got != 2 {
+				t.Errorf("Expected the latest collector's value 2, got %v", got)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected test_pool_connections to be registered")
+}
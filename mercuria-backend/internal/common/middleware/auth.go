@@ -2,13 +2,18 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kmassidik/mercuria/internal/common/apierror"
 	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/jwks"
+	"github.com/kmassidik/mercuria/internal/common/logger"
 )
 
 type contextKey string
@@ -16,77 +21,177 @@ type contextKey string
 const (
 	UserIDKey contextKey = "user_id"
 	EmailKey  contextKey = "email"
+	ClaimsKey contextKey = "claims"
 )
 
-// Claims represents JWT claims
+// Claims represents JWT claims. AMR lists the methods the user authenticated
+// with and ACR the resulting assurance level; AuthTime is when the most recent
+// factor was verified (see stepup.go).
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID   string           `json:"user_id"`
+	Email    string           `json:"email"`
+	Roles    []string         `json:"roles,omitempty"`
+	Scopes   []string         `json:"scopes,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+	ACR      string           `json:"acr,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTAuth middleware validates JWT tokens
+// TokenDenylist reports revoked access tokens by jti.
+// It is implemented by redis.Client.
+type TokenDenylist interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// JWTAuthConfig configures how JWTAuthWithConfig verifies tokens
+type JWTAuthConfig struct {
+	Keyfunc  jwt.Keyfunc
+	Methods  []string      // Accepted signing algorithms
+	Issuer   string        // Required iss claim, if set
+	Audience string        // Required aud claim, if set
+	Denylist TokenDenylist // Checked for revoked tokens, if set
+}
+
+// JWTAuth middleware validates HMAC-signed JWT tokens
 func JWTAuth(jwtSecret string) func(http.Handler) http.Handler {
+	return JWTAuthWithConfig(JWTAuthConfig{
+		Keyfunc: func(token *jwt.Token) (interface{}, error) {
+			return []byte(jwtSecret), nil
+		},
+		Methods: []string{"HS256", "HS384", "HS512"},
+	})
+}
+
+// JWTAuthFromConfig builds the JWTAuth middleware for the configured algorithm.
+// Asymmetric algorithms verify against the auth service's JWK Set, so services
+// never hold signing material.
+func JWTAuthFromConfig(cfg config.JWTConfig, denylist TokenDenylist) (func(http.Handler) http.Handler, error) {
+	authCfg := JWTAuthConfig{
+		Methods:  []string{cfg.Algorithm},
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		Denylist: denylist,
+	}
+
+	switch cfg.Algorithm {
+	case "HS256":
+		secret := []byte(cfg.Secret)
+		authCfg.Keyfunc = func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		}
+	case jwks.AlgRS256, jwks.AlgEdDSA:
+		if cfg.JWKSURL == "" {
+			return nil, fmt.Errorf("JWKS URL is required for %s", cfg.Algorithm)
+		}
+		authCfg.Keyfunc = jwks.NewCache(cfg.JWKSURL, cfg.JWKSCacheTTL).Keyfunc
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", cfg.Algorithm)
+	}
+
+	return JWTAuthWithConfig(authCfg), nil
+}
+
+// JWTAuthWithConfig middleware validates JWT tokens
+func JWTAuthWithConfig(cfg JWTAuthConfig) func(http.Handler) http.Handler {
+	opts := []jwt.ParserOption{jwt.WithValidMethods(cfg.Methods)}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"error":"missing authorization header"}`, http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.Unauthorized("missing authorization header"))
 				return
 			}
 
 			// Check Bearer format
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, `{"error":"invalid authorization format"}`, http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.Unauthorized("invalid authorization format"))
 				return
 			}
 
 			tokenString := parts[1]
 
-			// Parse and validate token
+			// Parse and validate token, including signing method, issuer and audience
 			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				// Validate signing method
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
+			token, err := jwt.ParseWithClaims(tokenString, claims, cfg.Keyfunc, opts...)
 
-			if err != nil || !token.Valid {
-				http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+			// Refresh tokens carry no user_id and must not be used as access tokens
+			if err != nil || !token.Valid || claims.UserID == "" {
+				apierror.Write(w, r, apierror.Unauthorized("invalid or expired token"))
 				return
 			}
 
+			// Reject tokens revoked by logout. Fail closed: a revoked token
+			// must never get through because the denylist is unreachable.
+			if cfg.Denylist != nil && claims.ID != "" {
+				revoked, err := cfg.Denylist.IsTokenRevoked(r.Context(), claims.ID)
+				if err != nil {
+					apierror.Write(w, r, apierror.Unavailable("authorization temporarily unavailable"))
+					return
+				}
+				if revoked {
+					apierror.Write(w, r, apierror.Unauthorized("token has been revoked"))
+					return
+				}
+			}
+
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, EmailKey, claims.Email)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
+			recordUser(ctx, claims.UserID)
 
 			// Call next handler
-			next.ServeHTTP(w, r.WithContext(ctx))
+			req := r.WithContext(ctx)
+			defer recordRoute(req)
+			next.ServeHTTP(w, req)
 		})
 	}
 }
 
-// GenerateToken generates a JWT access token
+// GenerateToken generates an HMAC-signed JWT access token
 func GenerateToken(userID, email string, cfg config.JWTConfig) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, NewAccessClaims(userID, email, cfg))
+	return token.SignedString([]byte(cfg.Secret))
+}
+
+// GenerateSignedToken generates a JWT access token signed with the current key
+// of the auth service's key set
+func GenerateSignedToken(userID, email string, cfg config.JWTConfig, keys *jwks.KeySet) (string, error) {
+	return keys.Sign(NewAccessClaims(userID, email, cfg))
+}
+
+// NewAccessClaims builds the claims of an access token with a fresh jti
+func NewAccessClaims(userID, email string, cfg config.JWTConfig) Claims {
 	claims := Claims{
 		UserID: userID,
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        NewTokenID(),
+			Issuer:    cfg.Issuer,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.Secret))
+	if cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
+	return claims
 }
 
-// GenerateRefreshToken generates a JWT refresh token
+// GenerateRefreshToken generates a stateless JWT refresh token.
+// Deprecated: it cannot be revoked; the auth service issues rotating refresh
+// tokens through auth.TokenService instead.
 func GenerateRefreshToken(userID string, cfg config.JWTConfig) (string, error) {
 	claims := jwt.RegisteredClaims{
 		Subject:   userID,
@@ -104,6 +209,29 @@ func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	return userID, ok
 }
 
+func init() {
+	// Logs written with an authenticated request's context carry its user
+	logger.RegisterContextField("user_id", func(ctx context.Context) (string, bool) {
+		userID, ok := GetUserIDFromContext(ctx)
+		return userID, ok && userID != ""
+	})
+}
+
+// NewTokenID returns a random token identifier for the jti claim
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate token id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// GetClaimsFromContext extracts the verified token claims from request context
+func GetClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ClaimsKey).(*Claims)
+	return claims, ok
+}
+
 // GetEmailFromContext extracts email from request context
 func GetEmailFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value(EmailKey).(string)
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+// Roles carried in access tokens
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Scopes carried in access tokens
+const (
+	ScopeAnalyticsGlobal = "analytics:global" // Platform-wide analytics
+	ScopeOutboxReplay    = "outbox:replay"    // Replaying outbox events
+)
+
+// roleScopes maps each role to the scopes it grants
+var roleScopes = map[string][]string{
+	RoleUser:  {},
+	RoleAdmin: {ScopeAnalyticsGlobal, ScopeOutboxReplay},
+}
+
+// ScopesForRoles returns the scopes granted by roles, for issuing tokens
+func ScopesForRoles(roles []string) []string {
+	var scopes []string
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// HasRole reports whether the token carries role
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasScope reports whether the token carries scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// RequireRole allows requests whose token carries any of roles.
+// It must run after JWTAuth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaimsFromContext(r.Context())
+			if !ok {
+				apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+				return
+			}
+
+			for _, role := range roles {
+				if claims.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			apierror.Write(w, r, apierror.Forbidden("insufficient role"))
+		})
+	}
+}
+
+// RequireScope allows requests whose token carries all of scopes.
+// It must run after JWTAuth.
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetClaimsFromContext(r.Context())
+			if !ok {
+				apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+				return
+			}
+
+			for _, scope := range scopes {
+				if !claims.HasScope(scope) {
+					apierror.Write(w, r, apierror.Forbidden("insufficient scope"))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// OwnerLookup returns the ID of the user owning a resource, e.g. the owner of
+// a wallet. found is false if the resource does not exist.
+type OwnerLookup func(ctx context.Context, resourceID string) (ownerID string, found bool, err error)
+
+// IsOwner reports whether the authenticated caller is ownerID
+func IsOwner(ctx context.Context, ownerID string) bool {
+	userID, ok := GetUserIDFromContext(ctx)
+	return ok && userID != "" && userID == ownerID
+}
+
+// RequireOwnership allows requests for resources owned by the caller. The
+// resource ID is read from the path wildcard param, e.g. "id" for
+// "GET /api/v1/wallets/{id}". Missing resources and resources of other users
+// both get 403, so callers cannot probe which IDs exist.
+// It must run after JWTAuth.
+func RequireOwnership(param string, lookup OwnerLookup, log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resourceID := r.PathValue(param)
+			if resourceID == "" {
+				apierror.Write(w, r, apierror.BadRequest("missing resource id"))
+				return
+			}
+
+			ownerID, found, err := lookup(r.Context(), resourceID)
+			if err != nil {
+				log.Errorf("Ownership check failed for %s: %v", resourceID, err)
+				apierror.Write(w, r, apierror.Internal(err))
+				return
+			}
+
+			if !found || !IsOwner(r.Context(), ownerID) {
+				apierror.Write(w, r, apierror.Forbidden("access to this resource is denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,20 +1,121 @@
 package middleware
 
-import "net/http"
-
-// CORS middleware adds CORS headers
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		// Handle preflight
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+)
+
+// originMatcher matches an exact origin, or a pattern with one "*"
+type originMatcher struct {
+	prefix, suffix string
+	wildcard       bool
+}
+
+func newOriginMatcher(pattern string) originMatcher {
+	prefix, suffix, wildcard := strings.Cut(strings.ToLower(pattern), "*")
+	return originMatcher{prefix: prefix, suffix: suffix, wildcard: wildcard}
+}
+
+func (m originMatcher) match(origin string) bool {
+	if !m.wildcard {
+		return origin == m.prefix
+	}
+	if len(origin) <= len(m.prefix)+len(m.suffix) ||
+		!strings.HasPrefix(origin, m.prefix) || !strings.HasSuffix(origin, m.suffix) {
+		return false
+	}
+	// The wildcard stands for subdomain labels or a port, never a path
+	return !strings.ContainsAny(origin[len(m.prefix):len(origin)-len(m.suffix)], "/@")
+}
+
+// CORS middleware applies the configured cross-origin policy. Allowed origins
+// are echoed back with Vary: Origin, so caches keep per-origin responses apart;
+// other origins get no CORS headers and their preflights are rejected. A "*"
+// origin is answered with "*" and never with credentials, whatever the config
+// says, since that would let any site make credentialed requests.
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	credentials := cfg.AllowCredentials && !anyOrigin
+	var matchers []originMatcher
+	for _, origin := range cfg.AllowedOrigins {
+		matchers = append(matchers, newOriginMatcher(origin))
+	}
+
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
 		}
+		origin = strings.ToLower(origin)
+		for _, m := range matchers {
+			if m.match(origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !slices.Contains(cfg.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
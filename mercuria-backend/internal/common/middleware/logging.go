@@ -1,47 +1,93 @@
 package middleware
 
 import (
+	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"time"
 
+	"github.com/kmassidik/mercuria/internal/common/config"
 	"github.com/kmassidik/mercuria/internal/common/logger"
 )
 
-// responseWriter wraps http.ResponseWriter to capture status code
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
-
-// Logging middleware logs HTTP requests
-func Logging(log *logger.Logger) func(http.Handler) http.Handler {
+// AccessLog middleware logs one entry per request with the method, route,
+// status, bytes written, duration, client IP (see RealIP), user agent and
+// authenticated user. The request ID and trace ID come from the context when
+// it runs inside RequestID and Tracing.
+//
+// Successful requests are sampled at cfg.SampleRate. Client errors, server
+// errors, slow requests and panics are always logged. A panic is logged as a
+// 500 and passed on to Recovery.
+func AccessLog(cfg config.AccessLogConfig, log *logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			wrapped := newResponseWriter(w)
+			req, info := withRequestInfo(r)
+
+			panicked := true
+			defer func() {
+				recordRoute(req)
+				logAccess(cfg, log, req, wrapped, info, time.Since(start), panicked)
+			}()
 
-			// Wrap response writer
-			wrapped := &responseWriter{
-				ResponseWriter: w,
-				statusCode:     http.StatusOK,
-			}
-
-			// Call next handler
-			next.ServeHTTP(wrapped, r)
-
-			// Log request
-			duration := time.Since(start)
-			log.Infof(
-				"%s %s %d %s",
-				r.Method,
-				r.URL.Path,
-				wrapped.statusCode,
-				duration,
-			)
+			next.ServeHTTP(wrapped, req)
+			panicked = false
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Logging middleware logs every HTTP request. It is AccessLog without sampling.
+func Logging(log *logger.Logger) func(http.Handler) http.Handler {
+	return AccessLog(config.AccessLogConfig{SampleRate: 1}, log)
+}
+
+func logAccess(cfg config.AccessLogConfig, log *logger.Logger, r *http.Request, rw *responseWriter, info *requestInfo, duration time.Duration, panicked bool) {
+	status := rw.statusCode
+	if panicked && !rw.wroteHeader {
+		status = http.StatusInternalServerError
+	}
+	slow := cfg.SlowThreshold > 0 && duration >= cfg.SlowThreshold
+
+	if !panicked && status < http.StatusBadRequest && !slow && !sampled(cfg.SampleRate) {
+		return
+	}
+
+	args := []interface{}{
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"bytes", rw.bytes,
+		"duration_ms", float64(duration.Microseconds()) / 1000,
+		"remote_ip", ClientIP(r),
+		"user_agent", r.UserAgent(),
+		"proto", r.Proto,
+	}
+	if info.route != "" {
+		args = append(args, "route", info.route)
+	}
+	if info.userID != "" {
+		args = append(args, "user_id", info.userID)
+	}
+	if rw.hijacked {
+		args = append(args, "hijacked", true)
+	}
+	if panicked {
+		args = append(args, "panic", true)
+	}
+
+	msg := fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status)
+	switch {
+	case panicked || status >= http.StatusInternalServerError:
+		log.ErrorContext(r.Context(), msg, args...)
+	case status >= http.StatusBadRequest || slow:
+		log.WarnContext(r.Context(), msg, args...)
+	default:
+		log.InfoContext(r.Context(), msg, args...)
+	}
+}
+
+// sampled reports whether to log a successful request at the given rate
+func sampled(rate float64) bool {
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+)
+
+// unmatchedRoute labels requests no ServeMux pattern matched, e.g. 404s for
+// scanned paths, so they share one series
+const unmatchedRoute = "unmatched"
+
+// Metrics middleware counts requests and observes their latency by method,
+// matched route and status code
+func Metrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapped := newResponseWriter(w)
+
+			req, info := withRequestInfo(r)
+			next.ServeHTTP(wrapped, req)
+			recordRoute(req)
+
+			route := info.route
+			if route == "" {
+				route = unmatchedRoute
+			}
+			status := strconv.Itoa(wrapped.statusCode)
+
+			method := metricsMethod(r.Method)
+
+			metrics.HTTPRequestsTotal.WithLabelValues(method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// metricsMethod bounds the method label to the standard methods
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
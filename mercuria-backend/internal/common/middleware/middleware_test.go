@@ -1,13 +1,34 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kmassidik/mercuria/internal/common/apierror"
 	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/jwks"
 	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestJWTAuth(t *testing.T) {
@@ -78,6 +99,370 @@ func TestJWTAuth(t *testing.T) {
 			if rr.Code != tt.expectedStatus {
 				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
 			}
+
+			// Errors are JSON, not text/plain
+			if tt.expectedStatus != http.StatusOK {
+				if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Expected Content-Type application/json, got %q", ct)
+				}
+				var body apierror.Response
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Fatalf("Failed to decode body: %v", err)
+				}
+				if body.Code != apierror.CodeUnauthorized {
+					t.Errorf("Expected code %s, got %s", apierror.CodeUnauthorized, body.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTAuthJWKS(t *testing.T) {
+	keys, err := jwks.NewKeySet(jwks.AlgEdDSA, 1)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+
+	// Stand-in for the auth service's /.well-known/jwks.json
+	server := httptest.NewServer(keys.Handler())
+	defer server.Close()
+
+	cfg := config.JWTConfig{
+		AccessTokenTTL: 15 * time.Minute,
+		Algorithm:      jwks.AlgEdDSA,
+		Issuer:         "mercuria-auth",
+		Audience:       "mercuria",
+		JWKSURL:        server.URL + jwks.WellKnownPath,
+		JWKSCacheTTL:   time.Minute,
+	}
+
+	auth, err := JWTAuthFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to build JWTAuth: %v", err)
+	}
+
+	valid, err := GenerateSignedToken("user-123", "test@example.com", cfg, keys)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	wrongAudience := cfg
+	wrongAudience.Audience = "other-platform"
+	foreign, err := GenerateSignedToken("user-123", "test@example.com", wrongAudience, keys)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Tokens signed with the old shared secret are no longer accepted
+	hmacToken, err := GenerateToken("user-123", "test@example.com", config.JWTConfig{
+		Secret:         "test-secret",
+		AccessTokenTTL: 15 * time.Minute,
+		Issuer:         cfg.Issuer,
+		Audience:       cfg.Audience,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{"valid token", valid, http.StatusOK},
+		{"wrong audience", foreign, http.StatusUnauthorized},
+		{"hmac token", hmacToken, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if userID, _ := GetUserIDFromContext(r.Context()); userID != "user-123" {
+					t.Errorf("Expected user ID 'user-123', got '%s'", userID)
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+type fakeDenylist struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (f *fakeDenylist) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return f.revoked[jti], f.err
+}
+
+func TestJWTAuthDenylist(t *testing.T) {
+	cfg := config.JWTConfig{
+		Secret:         "test-secret",
+		AccessTokenTTL: 15 * time.Minute,
+		Algorithm:      "HS256",
+	}
+
+	claims := NewAccessClaims("user-123", "test@example.com", cfg)
+	token, err := GenerateToken("user-123", "test@example.com", cfg)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	revokedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Secret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		denylist       *fakeDenylist
+		expectedStatus int
+	}{
+		{"not revoked", token, &fakeDenylist{}, http.StatusOK},
+		{"revoked", revokedToken, &fakeDenylist{revoked: map[string]bool{claims.ID: true}}, http.StatusUnauthorized},
+		{"denylist unavailable", token, &fakeDenylist{err: errors.New("connection refused")}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := JWTAuthFromConfig(cfg, tt.denylist)
+			if err != nil {
+				t.Fatalf("JWTAuthFromConfig failed: %v", err)
+			}
+
+			handler := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := GetClaimsFromContext(r.Context()); !ok {
+					t.Error("Expected claims in context")
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func withClaims(r *http.Request, claims *Claims) *http.Request {
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, ClaimsKey, claims)
+	return r.WithContext(ctx)
+}
+
+func TestRequireRoleAndScope(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	user := &Claims{UserID: "user-1", Roles: []string{RoleUser}}
+	admin := &Claims{UserID: "admin-1", Roles: []string{RoleAdmin}, Scopes: ScopesForRoles([]string{RoleAdmin})}
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		claims         *Claims
+		expectedStatus int
+	}{
+		{"admin role allowed", RequireRole(RoleAdmin)(ok), admin, http.StatusOK},
+		{"user role denied", RequireRole(RoleAdmin)(ok), user, http.StatusForbidden},
+		{"any of roles", RequireRole(RoleAdmin, RoleUser)(ok), user, http.StatusOK},
+		{"scope allowed", RequireScope(ScopeOutboxReplay)(ok), admin, http.StatusOK},
+		{"scope denied", RequireScope(ScopeAnalyticsGlobal)(ok), user, http.StatusForbidden},
+		{"unauthenticated", RequireRole(RoleAdmin)(ok), nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin", nil)
+			if tt.claims != nil {
+				req = withClaims(req, tt.claims)
+			}
+
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code == http.StatusForbidden && rr.Header().Get("Content-Type") != "application/json" {
+				t.Error("Expected JSON error body")
+			}
+		})
+	}
+}
+
+func TestRequireOwnership(t *testing.T) {
+	owners := map[string]string{"wallet-1": "user-1"}
+	lookup := func(ctx context.Context, walletID string) (string, bool, error) {
+		if walletID == "wallet-broken" {
+			return "", false, errors.New("db down")
+		}
+		owner, ok := owners[walletID]
+		return owner, ok, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /wallets/{id}", RequireOwnership("id", lookup, logger.New("test"))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+
+	tests := []struct {
+		name           string
+		userID         string
+		walletID       string
+		expectedStatus int
+	}{
+		{"owner", "user-1", "wallet-1", http.StatusOK},
+		{"other user", "user-2", "wallet-1", http.StatusForbidden},
+		{"missing wallet", "user-1", "wallet-404", http.StatusForbidden},
+		{"lookup error", "user-1", "wallet-broken", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest("GET", "/wallets/"+tt.walletID, nil), &Claims{UserID: tt.userID})
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRequireStepUp(t *testing.T) {
+	cfg := config.StepUpConfig{
+		MaxAge:             5 * time.Minute,
+		TransferThresholds: map[string]string{"USD": "1000.00", "JPY": "150000"},
+	}
+
+	var gotBody string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mfa := []string{AMRPassword, AMROTP}
+	fresh := &Claims{UserID: "user-1", AMR: mfa, ACR: ACRForAMR(mfa), AuthTime: jwt.NewNumericDate(time.Now())}
+	stale := &Claims{UserID: "user-1", AMR: mfa, ACR: ACRForAMR(mfa), AuthTime: jwt.NewNumericDate(time.Now().Add(-time.Hour))}
+	password := &Claims{UserID: "user-1", AMR: []string{AMRPassword}, ACR: ACRSingleFactor, AuthTime: jwt.NewNumericDate(time.Now())}
+
+	withdraw := RequireStepUp(cfg)(ok)
+	transfer := RequireStepUpAbove(cfg, JSONAmount("amount", "currency"), logger.New("test"))(ok)
+	batch := RequireStepUpAbove(cfg, JSONAmountSum("transfers", "amount", "currency"), logger.New("test"))(ok)
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		claims         *Claims
+		body           string
+		expectedStatus int
+	}{
+		{"withdrawal with recent MFA", withdraw, fresh, "", http.StatusOK},
+		{"withdrawal with stale MFA", withdraw, stale, "", http.StatusUnauthorized},
+		{"withdrawal with password only", withdraw, password, "", http.StatusUnauthorized},
+		{"small transfer", transfer, password, `{"amount": "50.00", "currency": "USD"}`, http.StatusOK},
+		{"transfer at threshold", transfer, password, `{"amount": "1000.00", "currency": "USD"}`, http.StatusOK},
+		{"large transfer", transfer, password, `{"amount": "1000.01", "currency": "USD"}`, http.StatusUnauthorized},
+		{"large transfer with recent MFA", transfer, fresh, `{"amount": "5000", "currency": "USD"}`, http.StatusOK},
+		{"threshold is per currency", transfer, password, `{"amount": "5000", "currency": "JPY"}`, http.StatusOK},
+		{"large transfer in another currency", transfer, password, `{"amount": "150001", "currency": "JPY"}`, http.StatusUnauthorized},
+		{"currency without threshold", transfer, password, `{"amount": "1.00", "currency": "EUR"}`, http.StatusUnauthorized},
+		{"invalid amount", transfer, password, `{"amount": "-5", "currency": "USD"}`, http.StatusBadRequest},
+		{"missing currency", transfer, password, `{"amount": "50.00"}`, http.StatusBadRequest},
+		{"amount finer than the currency", transfer, password, `{"amount": "50.5", "currency": "JPY"}`, http.StatusBadRequest},
+		{"batch over threshold", batch, password, `{"currency": "USD", "transfers": [{"amount": "600.00"}, {"amount": "600.00"}]}`, http.StatusUnauthorized},
+		{"batch under threshold", batch, password, `{"from_wallet_id": "w-1", "transfers": [{"amount": "100.00", "currency": "USD"}]}`, http.StatusOK},
+		{"case-variant amount key", transfer, password, `{"amount": "1.00", "currency": "USD", "AMOUNT": "5000.00"}`, http.StatusBadRequest},
+		{"duplicate amount key", transfer, password, `{"amount": "1.00", "currency": "USD", "amount": "5000.00"}`, http.StatusBadRequest},
+		{"case-variant amount key only", transfer, password, `{"Amount": "5000.00", "currency": "USD"}`, http.StatusBadRequest},
+		{"case-variant key in batch item", batch, password, `{"currency": "USD", "transfers": [{"amount": "1.00", "Amount": "5000.00"}]}`, http.StatusBadRequest},
+		{"batch in mixed currencies", batch, password, `{"transfers": [{"amount": "1.00", "currency": "USD"}, {"amount": "1", "currency": "JPY"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBody = ""
+			req := withClaims(httptest.NewRequest("POST", "/transfer", strings.NewReader(tt.body)), tt.claims)
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code == http.StatusUnauthorized && !strings.Contains(rr.Header().Get("WWW-Authenticate"), "insufficient_user_authentication") {
+				t.Errorf("Expected step-up challenge, got %q", rr.Header().Get("WWW-Authenticate"))
+			}
+			if rr.Code == http.StatusOK && gotBody != tt.body {
+				t.Errorf("Expected body %q to reach the handler, got %q", tt.body, gotBody)
+			}
+		})
+	}
+}
+
+func TestServiceAllowlist(t *testing.T) {
+	allowlist := ServiceAllowlist{
+		"GET /internal/v1/wallets/{id}": {"ledger"},
+	}
+
+	mux := http.NewServeMux()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := GetServiceIdentityFromContext(r.Context())
+		if !ok || identity.Name != "ledger" {
+			t.Errorf("Expected ledger identity in context, got %+v", identity)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	allowlist.Handle(mux, "GET /internal/v1/wallets/{id}", handler, logger.New("test"))
+	allowlist.Handle(mux, "POST /internal/v1/wallets/{id}/freeze", handler, logger.New("test"))
+
+	peer := func(commonName string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		tls            *tls.ConnectionState
+		expectedStatus int
+	}{
+		{"allowed service", "GET", "/internal/v1/wallets/w1", peer("ledger"), http.StatusOK},
+		{"other service", "GET", "/internal/v1/wallets/w1", peer("analytics"), http.StatusForbidden},
+		{"no client certificate", "GET", "/internal/v1/wallets/w1", nil, http.StatusUnauthorized},
+		{"route not in allowlist", "POST", "/internal/v1/wallets/w1/freeze", peer("ledger"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.TLS = tt.tls
+
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
 		})
 	}
 }
@@ -100,6 +485,393 @@ func TestLogging(t *testing.T) {
 	}
 }
 
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string // empty means a generated ID
+	}{
+		{"generated when missing", "", ""},
+		{"accepts valid ID", "3f2b9c1e-7a4d-4f0e-9b1a-2c5d8e6f7a90", "3f2b9c1e-7a4d-4f0e-9b1a-2c5d8e6f7a90"},
+		{"replaces invalid ID", "bad id\ninjected", ""},
+		{"replaces oversized ID", strings.Repeat("a", maxRequestIDLength+1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext, _ = requestid.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.header != "" {
+				req.Header.Set(requestid.Header, tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(requestid.Header)
+			if got == "" || got != fromContext {
+				t.Errorf("Expected response header to match context ID, got %q and %q", got, fromContext)
+			}
+			if tt.expected != "" && got != tt.expected {
+				t.Errorf("Expected request ID %q, got %q", tt.expected, got)
+			}
+			if tt.expected == "" && got == tt.header {
+				t.Errorf("Expected a generated request ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracing.SetupInMemory("test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Errorf("Expected span in handler context")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /api/v1/transfers", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := Tracing()(mux)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		traceparent    string
+		expectedName   string
+		expectedStatus codes.Code
+	}{
+		{"new trace", "GET", "/api/v1/wallets/w-1", "", "GET /api/v1/wallets/{id}", codes.Unset},
+		{"continues caller trace", "GET", "/api/v1/wallets/w-2", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "GET /api/v1/wallets/{id}", codes.Unset},
+		{"server error", "POST", "/api/v1/transfers", "", "POST /api/v1/transfers", codes.Error},
+		{"unmatched route", "GET", "/missing", "", "GET", codes.Unset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+			span := spans[0]
+
+			if span.Name != tt.expectedName {
+				t.Errorf("Expected span name %q, got %q", tt.expectedName, span.Name)
+			}
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("Expected server span, got %v", span.SpanKind)
+			}
+			if span.Status.Code != tt.expectedStatus {
+				t.Errorf("Expected status %v, got %v", tt.expectedStatus, span.Status.Code)
+			}
+			if tt.traceparent != "" && span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("Expected caller's trace ID, got %s", span.SpanContext.TraceID())
+			}
+		})
+	}
+}
+
+func TestTracingAndAccessLogStacked(t *testing.T) {
+	exporter := tracing.SetupInMemory("test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var buf bytes.Buffer
+	log := logger.NewWithOptions("test", logger.Options{Output: &buf})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{"tracing around logging", Tracing()(Logging(log)(mux))},
+		{"with request ID and real IP in between", Tracing()(RequestID()(Logging(log)(RealIP(nil)(mux))))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			buf.Reset()
+
+			tt.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/wallets/w-1", nil))
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+			if spans[0].Name != "GET /api/v1/wallets/{id}" {
+				t.Errorf("Expected span named after the route, got %q", spans[0].Name)
+			}
+			var route string
+			for _, attr := range spans[0].Attributes {
+				if attr.Key == semconv.HTTPRouteKey {
+					route = attr.Value.AsString()
+				}
+			}
+			if route != "GET /api/v1/wallets/{id}" {
+				t.Errorf("Expected http.route attribute, got %q", route)
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("Expected one JSON access log entry, got %q", buf.String())
+			}
+			if entry["route"] != "GET /api/v1/wallets/{id}" {
+				t.Errorf("Expected route in access log, got %v", entry["route"])
+			}
+			if entry["trace_id"] != spans[0].SpanContext.TraceID().String() {
+				t.Errorf("Expected trace_id %s, got %v", spans[0].SpanContext.TraceID(), entry["trace_id"])
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Metrics()(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		labels []string
+	}{
+		{"labels by route, not path", "GET", "/api/v1/wallets/w-1", []string{"GET", "GET /api/v1/wallets/{id}", "200"}},
+		{"unmatched route", "GET", "/wp-login.php", []string{"GET", unmatchedRoute, "404"}},
+		{"non-standard method", "PROPFIND", "/api/v1/wallets/w-1", []string{"OTHER", unmatchedRoute, "405"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequestsTotal.WithLabelValues(tt.labels...)
+			before := testutil.ToFloat64(counter)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("Expected counter %v to increase by 1, got %v", tt.labels, got)
+			}
+		})
+	}
+}
+
+func TestMetricsStacked(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/accounts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	log := logger.NewWithOptions("test", logger.Options{Output: io.Discard})
+
+	// A middleware from outside this package that copies the request
+	copying := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(r.Context()))
+		})
+	}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{"inside RequestID", Metrics()(RequestID()(mux))},
+		{"inside RequestID, RealIP and AccessLog", Metrics()(RequestID()(RealIP(nil)(AccessLog(config.AccessLogConfig{SampleRate: 1}, log)(mux))))},
+		{"outside copying middleware with RecordRoute", Metrics()(copying(RecordRoute(mux)))},
+	}
+
+	counter := metrics.HTTPRequestsTotal.WithLabelValues("GET", "GET /api/v1/accounts/{id}", "200")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(counter)
+
+			tt.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/accounts/a-1", nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("Expected the matched route to be counted once, got %v", got)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		handler    http.HandlerFunc
+		expectLog  bool
+		level      string
+		status     float64
+	}{
+		{
+			name:       "success is logged",
+			sampleRate: 1,
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+			expectLog:  true,
+			level:      "INFO",
+			status:     200,
+		},
+		{
+			name:       "success is sampled out",
+			sampleRate: 0,
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+			expectLog:  false,
+		},
+		{
+			name:       "client error is always logged",
+			sampleRate: 0,
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			expectLog:  true,
+			level:      "WARN",
+			status:     404,
+		},
+		{
+			name:       "panic is logged as 500",
+			sampleRate: 0,
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectLog:  true,
+			level:      "ERROR",
+			status:     500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logger.NewWithOptions("test", logger.Options{Output: &buf})
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+				recordUser(r.Context(), "user-123")
+				tt.handler(w, r)
+			})
+			handler := Recovery(logger.NewWithOptions("test", logger.Options{Output: io.Discard}))(
+				AccessLog(config.AccessLogConfig{SampleRate: tt.sampleRate}, log)(mux))
+
+			req := httptest.NewRequest("GET", "/api/v1/wallets/w-1", nil)
+			req.Header.Set("User-Agent", "mercuria-test")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.expectLog {
+				if buf.Len() != 0 {
+					t.Errorf("Expected no access log, got %s", buf.String())
+				}
+				return
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("Expected one JSON access log entry, got %q", buf.String())
+			}
+
+			expected := map[string]interface{}{
+				"level":      tt.level,
+				"status":     tt.status,
+				"route":      "GET /api/v1/wallets/{id}",
+				"user_id":    "user-123",
+				"user_agent": "mercuria-test",
+				"remote_ip":  "192.0.2.1",
+			}
+			for key, value := range expected {
+				if entry[key] != value {
+					t.Errorf("Expected %s=%v, got %v", key, value, entry[key])
+				}
+			}
+			if tt.status == 200 && entry["bytes"] != float64(5) {
+				t.Errorf("Expected 5 bytes, got %v", entry["bytes"])
+			}
+			if tt.status == 500 && entry["panic"] != true {
+				t.Errorf("Expected panic=true, got %v", entry["panic"])
+			}
+		})
+	}
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rw := newResponseWriter(rr)
+	var w http.ResponseWriter = rw
+
+	// Streaming handlers reach the recorder's Flush, directly and through ResponseController
+	w.(http.Flusher).Flush()
+	if !rr.Flushed {
+		t.Errorf("Expected Flush to reach the underlying writer")
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("Expected ResponseController to flush, got %v", err)
+	}
+
+	// The recorder cannot be hijacked
+	if _, _, err := rw.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+
+	if _, err := io.Copy(rw, strings.NewReader("streamed body")); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	rw.Write([]byte("!"))
+	if rw.bytes != 14 || rr.Body.String() != "streamed body!" {
+		t.Errorf("Expected 14 bytes counted, got %d (%q)", rw.bytes, rr.Body.String())
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"behind trusted proxy", "10.0.0.5:5000", "198.51.100.9", "198.51.100.9"},
+		{"skips trusted hops", "10.0.0.5:5000", "198.51.100.9, 10.0.0.2", "198.51.100.9"},
+		{"ignores client-supplied prefix", "10.0.0.5:5000", "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"garbage hop", "10.0.0.5:5000", "not-an-ip, 10.0.0.2", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.expectedIP {
+				t.Errorf("Expected client IP %s, got %s", tt.expectedIP, got)
+			}
+		})
+	}
+}
+
 func TestRecovery(t *testing.T) {
 	log := logger.New("test")
 
@@ -115,23 +887,233 @@ func TestRecovery(t *testing.T) {
 	if rr.Code != http.StatusInternalServerError {
 		t.Errorf("Expected status 500, got %d", rr.Code)
 	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+
+	var body apierror.Response
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Code != apierror.CodeInternal {
+		t.Errorf("Expected code %s, got %s", apierror.CodeInternal, body.Code)
+	}
+	if strings.Contains(body.Message, "test panic") {
+		t.Errorf("Expected panic value to be hidden, got %q", body.Message)
+	}
 }
 
 func TestCORS(t *testing.T) {
-	handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	cfg := config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.mercuria.io", "https://*.preview.mercuria.io"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	handler := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
+	tests := []struct {
+		name           string
+		method         string
+		origin         string
+		requestMethod  string
+		expectedStatus int
+		expectedOrigin string
+	}{
+		{"preflight from frontend", "OPTIONS", "https://app.mercuria.io", "POST", http.StatusNoContent, "https://app.mercuria.io"},
+		{"preflight from preview pattern", "OPTIONS", "https://pr-42.preview.mercuria.io", "POST", http.StatusNoContent, "https://pr-42.preview.mercuria.io"},
+		{"preflight from unknown origin", "OPTIONS", "https://evil.example", "POST", http.StatusForbidden, ""},
+		{"preflight for disallowed method", "OPTIONS", "https://app.mercuria.io", "DELETE", http.StatusForbidden, "https://app.mercuria.io"},
+		{"request from frontend", "GET", "https://app.mercuria.io", "", http.StatusOK, "https://app.mercuria.io"},
+		{"request from unknown origin", "GET", "https://evil.example", "", http.StatusOK, ""},
+		{"pattern does not match bare domain", "GET", "https://preview.mercuria.io", "", http.StatusOK, ""},
+		{"same-origin request", "GET", "", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.expectedOrigin {
+				t.Errorf("Expected allowed origin %q, got %q", tt.expectedOrigin, got)
+			}
+			if rr.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %q", rr.Header().Values("Vary"))
+			}
+		})
+	}
+
+	// Preflight responses carry the policy
 	req := httptest.NewRequest("OPTIONS", "/test", nil)
+	req.Header.Set("Origin", "https://app.mercuria.io")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key") {
+		t.Errorf("Expected Idempotency-Key to be allowed, got %q", rr.Header().Get("Access-Control-Allow-Headers"))
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected credentials to be allowed")
+	}
+	if rr.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected max age 600, got %q", rr.Header().Get("Access-Control-Max-Age"))
+	}
+
+	// Any origin is never allowed to send credentials
+	cfg.AllowedOrigins = []string{"*"}
+	handler = CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected allowed origin *, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials for any origin, got %q", got)
+	}
+}
+
+// fakeRateLimiter allows a fixed number of requests per key
+type fakeRateLimiter struct {
+	counts map[string]int
+	err    error
+}
+
+func (f *fakeRateLimiter) AllowRequest(ctx context.Context, key string, limit int, window time.Duration) (redis.RateLimitResult, error) {
+	if f.err != nil {
+		return redis.RateLimitResult{}, f.err
+	}
+	f.counts[key]++
+	count := f.counts[key]
+	if count > limit {
+		return redis.RateLimitResult{Allowed: false, Limit: limit, Remaining: 0, ResetAfter: 1500 * time.Millisecond}, nil
+	}
+	return redis.RateLimitResult{Allowed: true, Limit: limit, Remaining: limit - count, ResetAfter: window}, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	log := logger.New("test")
+	limiter := &fakeRateLimiter{counts: map[string]int{}}
+	rule := RateLimitRule{Name: "login", Limit: 2, Window: time.Minute, KeyFunc: KeyByIP}
+
+	handler := RateLimit(limiter, rule, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/v1/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i+1, rr.Code)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("Expected RateLimit-Limit 2, got %q", rr.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/login", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
 	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", rr.Header().Get("RateLimit-Remaining"))
+	}
 
+	// Other clients have their own budget
+	req = httptest.NewRequest("POST", "/api/v1/login", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+		t.Errorf("Expected status 200 for another IP, got %d", rr.Code)
 	}
+}
 
-	if rr.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Error("Expected CORS header to be set")
+func TestKeyByAPIKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-API-Key", "sk_live_secret")
+
+	key := KeyByAPIKey(req)
+	if strings.Contains(key, "sk_live_secret") {
+		t.Errorf("Expected the API key to be hashed, got %q", key)
+	}
+	if !strings.HasPrefix(key, "apikey:") || key != KeyByAPIKey(req) {
+		t.Errorf("Expected a stable apikey: key, got %q", key)
 	}
-}
\ No newline at end of file
+
+	other := httptest.NewRequest("GET", "/test", nil)
+	other.Header.Set("X-API-Key", "sk_live_other")
+	if KeyByAPIKey(other) == key {
+		t.Error("Expected different API keys to be counted apart")
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	log := logger.New("test")
+	limiter := &fakeRateLimiter{err: errors.New("redis down")}
+	cfg := config.RateLimitConfig{Enabled: true, DefaultLimit: 1, DefaultWindow: time.Minute, LoginLimit: 1, LoginWindow: time.Minute}
+
+	tests := []struct {
+		name           string
+		rule           RateLimitRule
+		expectedStatus int
+	}{
+		{"default rule fails open", DefaultRateLimitRule(cfg), http.StatusOK},
+		{"login rule fails closed", LoginRateLimitRule(cfg), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RateLimit(limiter, tt.rule, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d when limiter fails, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusServiceUnavailable {
+				var body apierror.Response
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || body.Code != apierror.CodeUnavailable {
+					t.Errorf("Expected a service_unavailable error body, got %+v (%v)", body, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/redis"
+)
+
+// RateLimiter checks a request against a limit per window.
+// It is implemented by redis.Client.
+type RateLimiter interface {
+	AllowRequest(ctx context.Context, key string, limit int, window time.Duration) (redis.RateLimitResult, error)
+}
+
+// RateLimitKeyFunc returns the identity a request is counted against
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitRule configures the limit for a route or group of routes
+type RateLimitRule struct {
+	Name       string // Route group, e.g. "login" or "transfer"
+	Limit      int
+	Window     time.Duration
+	KeyFunc    RateLimitKeyFunc
+	FailClosed bool // Reject requests with 503 while the limiter is unavailable
+}
+
+// DefaultRateLimitRule limits general API traffic per user
+func DefaultRateLimitRule(cfg config.RateLimitConfig) RateLimitRule {
+	return newRateLimitRule(cfg, "default", cfg.DefaultLimit, cfg.DefaultWindow, KeyByUserID)
+}
+
+// LoginRateLimitRule limits login attempts per client IP to slow down brute
+// force. It fails closed, so a Redis outage does not lift the limit.
+func LoginRateLimitRule(cfg config.RateLimitConfig) RateLimitRule {
+	rule := newRateLimitRule(cfg, "login", cfg.LoginLimit, cfg.LoginWindow, KeyByIP)
+	rule.FailClosed = true
+	return rule
+}
+
+// TransferRateLimitRule limits transfers per user
+func TransferRateLimitRule(cfg config.RateLimitConfig) RateLimitRule {
+	return newRateLimitRule(cfg, "transfer", cfg.TransferLimit, cfg.TransferWindow, KeyByUserID)
+}
+
+func newRateLimitRule(cfg config.RateLimitConfig, name string, limit int, window time.Duration, keyFunc RateLimitKeyFunc) RateLimitRule {
+	if !cfg.Enabled {
+		limit = 0 // Disables the middleware
+	}
+	return RateLimitRule{Name: name, Limit: limit, Window: window, KeyFunc: keyFunc}
+}
+
+// KeyByIP counts requests per client IP
+func KeyByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// KeyByUserID counts requests per authenticated user, falling back to the
+// client IP. Must run after JWTAuth.
+func KeyByUserID(r *http.Request) string {
+	if userID, ok := GetUserIDFromContext(r.Context()); ok && userID != "" {
+		return "user:" + userID
+	}
+	return KeyByIP(r)
+}
+
+// KeyByAPIKey counts requests per X-API-Key header, falling back to the client
+// IP. The key is hashed, so no credentials end up in Redis keys or logs.
+func KeyByAPIKey(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "apikey:" + hex.EncodeToString(sum[:16])
+	}
+	return KeyByIP(r)
+}
+
+// RateLimit middleware rejects requests over the rule's limit with 429.
+// If the limiter is unavailable the request is let through, so a Redis
+// outage does not take the API down, unless the rule fails closed.
+func RateLimit(limiter RateLimiter, rule RateLimitRule, log *logger.Logger) func(http.Handler) http.Handler {
+	keyFunc := rule.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		if rule.Limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := rule.Name + ":" + keyFunc(r)
+
+			result, err := limiter.AllowRequest(r.Context(), key, rule.Limit, rule.Window)
+			if err != nil {
+				log.Errorf("Rate limit check failed for %s: %v", key, err)
+				if rule.FailClosed {
+					apierror.Write(w, r, apierror.Unavailable("rate limiting temporarily unavailable"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			resetSeconds := strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", resetSeconds)
+
+			if !result.Allowed {
+				log.Warnf("Rate limit exceeded: %s %s (%s)", r.Method, r.URL.Path, key)
+				apierror.Write(w, r, apierror.RateLimited("rate limit exceeded", result.ResetAfter))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
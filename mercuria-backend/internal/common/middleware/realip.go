@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// RealIP middleware resolves the client IP behind trusted proxies and stores
+// it for ClientIP. X-Forwarded-For is only believed when the connecting peer
+// is trusted; it is then read right to left, skipping trusted hops, so a
+// client cannot spoof its IP by sending the header itself.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trustedProxies)
+			req := r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+			defer recordRoute(req)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// ClientIP returns the IP of the client, as resolved by RealIP, or the IP of
+// the connecting peer outside RealIP
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+func resolveClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer := peerIP(r)
+	if !isTrusted(peer, trustedProxies) {
+		return peer
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break // Garbage from an untrusted hop; keep the last good address
+		}
+		client = addr.Unmap().String()
+		if !isTrusted(client, trustedProxies) {
+			break
+		}
+	}
+	return client
+}
+
+func isTrusted(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP returns the IP of the connecting peer
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
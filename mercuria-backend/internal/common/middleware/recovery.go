@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"runtime/debug"
 
+	"github.com/kmassidik/mercuria/internal/common/apierror"
 	"github.com/kmassidik/mercuria/internal/common/logger"
 )
 
@@ -14,11 +16,10 @@ func Recovery(log *logger.Logger) func(http.Handler) http.Handler {
 			defer func() {
 				if err := recover(); err != nil {
 					// Log panic with stack trace
-					log.Errorf("PANIC: %v\n%s", err, debug.Stack())
+					log.ErrorContext(r.Context(), fmt.Sprintf("PANIC: %v", err), "stack", string(debug.Stack()))
 
 					// Return 500 error
-					w.WriteHeader(http.StatusInternalServerError)
-					w.Write([]byte(`{"error":"internal server error"}`))
+					apierror.Write(w, r, apierror.Internal(fmt.Errorf("panic: %v", err)))
 				}
 			}()
 
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs, which end up in logs
+// and Kafka headers
+const maxRequestIDLength = 128
+
+// RequestID middleware accepts the caller's X-Request-ID, or generates one,
+// stores it in the request context and echoes it in the response. It should
+// wrap every other middleware, so their logs carry the ID.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestid.Header)
+			if !validRequestID(id) {
+				id = requestid.New()
+			}
+
+			w.Header().Set(requestid.Header, id)
+			req := r.WithContext(requestid.NewContext(r.Context(), id))
+			defer recordRoute(req)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// validRequestID accepts IDs of printable, log-safe characters, such as
+// UUIDs and the hex IDs requestid.New generates
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// requestInfo carries what inner layers learn about a request back out to
+// Metrics, Tracing and AccessLog: the matched route, and the user JWTAuth
+// authenticated. ServeMux sets Pattern only on the request it is given, and
+// every middleware that adds a context value passes on a copy, so the outer
+// layers cannot read the route from their own request.
+type requestInfo struct {
+	route  string
+	userID string
+}
+
+type requestInfoKey struct{}
+
+// withRequestInfo returns a copy of r carrying a requestInfo, reusing the one
+// an outer middleware installed
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return r.WithContext(r.Context()), info
+	}
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+// recordRoute reports the pattern ServeMux matched on r, if any. Middleware
+// that pass a copy of the request on call it once next returns; the innermost
+// copy is the one the mux saw, so the first route recorded wins.
+func recordRoute(r *http.Request) {
+	info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	if ok && info.route == "" && r.Pattern != "" {
+		info.route = r.Pattern
+	}
+}
+
+// recordUser reports the authenticated user, if the request runs inside
+// AccessLog
+func recordUser(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+// RecordRoute reports the matched route to Metrics, Tracing and AccessLog.
+// Middleware in this package do this themselves; wrap the ServeMux with it
+// when middleware from elsewhere copies the request in between.
+func RecordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recordRoute(r)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// bytes written. It keeps the optional interfaces of the writer it wraps:
+// Flush and Hijack are passed through, and Unwrap lets http.ResponseController
+// reach the original writer.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses, e.g. 103 Early Hints, precede the real status
+	if !rw.wroteHeader && code >= http.StatusOK {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the sendfile fast path of io.Copy to the response
+func (rw *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rw.wroteHeader = true
+
+	var n int64
+	var err error
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{rw.ResponseWriter}, r)
+	}
+	rw.bytes += n
+	return n, err
+}
+
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		rw.hijacked = true
+		if !rw.wroteHeader {
+			rw.statusCode = http.StatusSwitchingProtocols
+		}
+	}
+	return conn, buf, err
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// writerOnly hides ReadFrom, so io.Copy does not call back into responseWriter
+type writerOnly struct {
+	io.Writer
+}
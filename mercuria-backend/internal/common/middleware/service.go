@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/mtls"
+)
+
+const ServiceIdentityKey contextKey = "service_identity"
+
+// ServiceAllowlist maps internal route patterns, as registered on
+// http.ServeMux, to the services allowed to call them, e.g.
+//
+//	"GET /internal/v1/wallets/{id}": {"ledger", "transaction"}
+type ServiceAllowlist map[string][]string
+
+// Handle registers an internal route on mux, callable only by the services
+// the allowlist names for pattern. Routes missing from the allowlist reject
+// every caller, so a forgotten entry fails closed.
+func (a ServiceAllowlist) Handle(mux *http.ServeMux, pattern string, handler http.Handler, log *logger.Logger) {
+	mux.Handle(pattern, RequireService(log, a[pattern]...)(handler))
+}
+
+// RequireService authenticates the calling service by the client certificate
+// verified during the mTLS handshake, and allows only the listed services.
+// Internal endpoints use it instead of JWTAuth; the server must use
+// mtls.Config.ServerTLSConfig so that client certificates are verified.
+func RequireService(log *logger.Logger, services ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, err := mtls.PeerIdentity(r.TLS)
+			if err != nil {
+				apierror.Write(w, r, apierror.Unauthorized("client certificate required"))
+				return
+			}
+
+			if !slices.Contains(services, identity.Name) {
+				log.Warnf("Service %s denied: %s %s", identity.Name, r.Method, r.URL.Path)
+				apierror.Write(w, r, apierror.Forbidden("service not allowed"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ServiceIdentityKey, identity)
+			req := r.WithContext(ctx)
+			defer recordRoute(req)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// GetServiceIdentityFromContext extracts the calling service from request context
+func GetServiceIdentityFromContext(ctx context.Context) (mtls.Identity, bool) {
+	identity, ok := ctx.Value(ServiceIdentityKey).(mtls.Identity)
+	return identity, ok
+}
@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+// Authentication methods carried in the amr claim (RFC 8176)
+const (
+	AMRPassword     = "pwd"
+	AMROTP          = "otp"
+	AMRRecoveryCode = "rec" // A single-use TOTP recovery code
+)
+
+// Assurance levels carried in the acr claim
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// maxAmountBody caps how much of a request body AmountFunc helpers read
+const maxAmountBody = 1 << 20
+
+// CodeStepUpRequired is the error code of the step-up challenge
+const CodeStepUpRequired apierror.Code = "step_up_required"
+
+// ACRForAMR returns the assurance level reached by the given methods
+func ACRForAMR(amr []string) string {
+	if slices.Contains(amr, AMRPassword) && (slices.Contains(amr, AMROTP) || slices.Contains(amr, AMRRecoveryCode)) {
+		return ACRMultiFactor
+	}
+	return ACRSingleFactor
+}
+
+// HasRecentMFA reports whether the token proves a second factor verified
+// within maxAge
+func (c *Claims) HasRecentMFA(maxAge time.Duration) bool {
+	return c.ACR == ACRMultiFactor && c.AuthTime != nil && time.Since(c.AuthTime.Time) <= maxAge
+}
+
+// RequireStepUp allows requests whose token proves a second factor verified
+// within cfg.MaxAge, e.g. for withdrawals. Other requests get 401 with an
+// RFC 9470 challenge telling the client to step up through the auth service.
+// It must run after JWTAuth.
+func RequireStepUp(cfg config.StepUpConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requireRecentMFA(w, r, cfg.MaxAge) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// AmountFunc extracts the amount of money a request moves
+type AmountFunc func(r *http.Request) (money.Money, error)
+
+// RequireStepUpAbove requires step-up, as RequireStepUp, for requests moving
+// more than the cfg.TransferThresholds entry for their currency. Currencies
+// without a threshold always require step-up. Requests whose amount cannot be
+// read get 400. It must run after JWTAuth.
+func RequireStepUpAbove(cfg config.StepUpConfig, amount AmountFunc, log *logger.Logger) func(http.Handler) http.Handler {
+	thresholds := make(map[string]money.Money, len(cfg.TransferThresholds))
+	for currency, value := range cfg.TransferThresholds {
+		threshold, err := money.Parse(value, currency)
+		if err != nil {
+			// Fail closed; config.Load rejects invalid thresholds anyway
+			log.Errorf("Invalid step-up threshold %q for %s, requiring step-up for all amounts: %v", value, currency, err)
+			continue
+		}
+		thresholds[currency] = threshold
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value, err := amount(r)
+			if err != nil {
+				apierror.Write(w, r, apierror.BadRequest("invalid amount"))
+				return
+			}
+
+			if belowThreshold(value, thresholds) || requireRecentMFA(w, r, cfg.MaxAge) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func belowThreshold(value money.Money, thresholds map[string]money.Money) bool {
+	threshold, ok := thresholds[value.Currency()]
+	if !ok {
+		return false
+	}
+	c, err := value.Cmp(threshold)
+	return err == nil && c <= 0
+}
+
+// requireRecentMFA writes the step-up challenge and returns false unless the
+// caller verified a second factor within maxAge
+func requireRecentMFA(w http.ResponseWriter, r *http.Request, maxAge time.Duration) bool {
+	claims, ok := GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("missing authorization"))
+		return false
+	}
+	if claims.HasRecentMFA(maxAge) {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+		`Bearer error="insufficient_user_authentication", error_description="step-up authentication required", acr_values="%s", max_age=%d`,
+		ACRMultiFactor, int(maxAge.Seconds())))
+	apierror.Write(w, r, apierror.WithStatus(http.StatusUnauthorized, CodeStepUpRequired, "step-up authentication required"))
+	return false
+}
+
+// JSONAmount reads a decimal amount such as "50.00" and its currency from
+// top-level fields of the JSON body. The body is restored for the next handler.
+func JSONAmount(amountField, currencyField string) AmountFunc {
+	return func(r *http.Request) (money.Money, error) {
+		var body map[string]json.RawMessage
+		if err := peekJSON(r, &body); err != nil {
+			return money.Money{}, err
+		}
+		return parseAmount(body[amountField], body[currencyField])
+	}
+}
+
+// JSONAmountSum sums the amounts in amountField of each element of the
+// listField array, e.g. the transfers of a batch transfer. Each element's
+// currency is read from currencyField, falling back to the top-level field;
+// all elements must share one currency.
+func JSONAmountSum(listField, amountField, currencyField string) AmountFunc {
+	return func(r *http.Request) (money.Money, error) {
+		var body map[string]json.RawMessage
+		if err := peekJSON(r, &body); err != nil {
+			return money.Money{}, err
+		}
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(body[listField], &items); err != nil || len(items) == 0 {
+			return money.Money{}, fmt.Errorf("invalid %s", listField)
+		}
+
+		var total money.Money
+		for i, item := range items {
+			currency, ok := item[currencyField]
+			if !ok {
+				currency = body[currencyField]
+			}
+			value, err := parseAmount(item[amountField], currency)
+			if err != nil {
+				return money.Money{}, err
+			}
+			if i == 0 {
+				total = value
+				continue
+			}
+			if total, err = total.Add(value); err != nil {
+				return money.Money{}, err
+			}
+		}
+		return total, nil
+	}
+}
+
+func peekJSON(r *http.Request, v interface{}) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAmountBody+1))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(data) > maxAmountBody {
+		return fmt.Errorf("body exceeds %d bytes", maxAmountBody)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if err := checkKeys(json.NewDecoder(bytes.NewReader(data))); err != nil {
+		return fmt.Errorf("failed to decode body: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode body: %w", err)
+	}
+	return nil
+}
+
+// checkKeys rejects JSON values with an object that repeats a key, ignoring
+// case. Handlers decode bodies into structs, where encoding/json matches
+// keys case-insensitively and the last one wins; without this check
+// {"amount": "1.00", "AMOUNT": "5000.00"} would be judged on 1.00 here and
+// move 5000.00 there.
+func checkKeys(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]bool)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			folded := foldKey(key.(string))
+			if seen[folded] {
+				return fmt.Errorf("duplicate key %q", key)
+			}
+			seen[folded] = true
+			if err := checkKeys(dec); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	case json.Delim('['):
+		for dec.More() {
+			if err := checkKeys(dec); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+	return nil
+}
+
+// foldKey folds a key the way encoding/json does when matching struct fields
+func foldKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToUpper(unicode.ToLower(r))
+	}, key)
+}
+
+// parseAmount accepts a positive amount as a JSON string or number, in the
+// currency given as a JSON string
+func parseAmount(rawAmount, rawCurrency json.RawMessage) (money.Money, error) {
+	var amount string
+	if err := json.Unmarshal(rawAmount, &amount); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(rawAmount, &n); err != nil {
+			return money.Money{}, fmt.Errorf("invalid amount %s", rawAmount)
+		}
+		amount = n.String()
+	}
+	var currency string
+	if err := json.Unmarshal(rawCurrency, &currency); err != nil {
+		return money.Money{}, fmt.Errorf("invalid currency %s", rawCurrency)
+	}
+
+	value, err := money.Parse(amount, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if !value.IsPositive() {
+		return money.Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	return value, nil
+}
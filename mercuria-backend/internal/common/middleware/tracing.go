@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing middleware starts a server span for each request, continuing the
+// caller's trace from its traceparent header. The span is named after the
+// matched ServeMux pattern once the handler has run, e.g. "POST /api/v1/login".
+// It should wrap Logging, so request logs carry trace_id.
+func Tracing() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracing.Tracer().Start(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.URLPath(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			wrapped := newResponseWriter(w)
+
+			req, info := withRequestInfo(r.WithContext(ctx))
+			next.ServeHTTP(wrapped, req)
+			recordRoute(req)
+
+			if info.route != "" {
+				name := info.route
+				if !strings.Contains(name, " ") {
+					name = r.Method + " " + name
+				}
+				span.SetName(name)
+				span.SetAttributes(semconv.HTTPRoute(info.route))
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.statusCode))
+			if wrapped.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package money
+
+// minorUnits lists the supported ISO 4217 currencies and the number of
+// decimal places of their minor unit
+var minorUnits = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"IDR": 2,
+}
+
+// currencies is minorUnits in display order
+var currencies = []string{"USD", "EUR", "GBP", "JPY", "IDR"}
+
+// Currencies returns the ISO 4217 codes wallets can hold
+func Currencies() []string {
+	codes := make([]string, len(currencies))
+	copy(codes, currencies)
+	return codes
+}
+
+// Scale returns the number of decimal places of a currency's minor unit,
+// e.g. 2 for USD (cents) and 0 for JPY
+func Scale(currency string) (int, bool) {
+	scale, ok := minorUnits[currency]
+	return scale, ok
+}
+
+// IsSupported reports whether currency is a supported ISO 4217 code
+func IsSupported(currency string) bool {
+	_, ok := minorUnits[currency]
+	return ok
+}
+
+// pow10 returns 10^n for the small exponents of minorUnits
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,341 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency   = errors.New("unsupported currency")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrPrecision         = errors.New("amount has more decimal places than the currency allows")
+	ErrOverflow          = errors.New("amount out of range")
+	ErrInvalidAllocation = errors.New("invalid allocation")
+)
+
+// RoundingMode decides how amounts between two minor units are rounded
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // To the nearest unit, ties to even (banker's rounding)
+	RoundHalfUp                       // To the nearest unit, ties away from zero
+	RoundDown                         // Toward zero
+)
+
+// Money is an exact amount in the minor units of a currency, e.g. 1050 USD
+// is $10.50 and 1050 JPY is ¥1050. Floats never touch a Money. The zero
+// value is zero in no currency; use Zero or Parse for a usable value.
+type Money struct {
+	amount   int64
+	currency string
+}
+
+// New creates an amount of minor units in currency
+func New(minorUnits int64, currency string) (Money, error) {
+	if !IsSupported(currency) {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return Money{amount: minorUnits, currency: currency}, nil
+}
+
+// Zero returns zero in currency
+func Zero(currency string) Money {
+	return Money{currency: currency}
+}
+
+// Parse parses a decimal amount such as "1000.00" or "-5". It is exact:
+// digits beyond the currency's minor unit must be zeros, so "10.500" is a
+// valid USD amount but "10.505" is not.
+func Parse(amount, currency string) (Money, error) {
+	scale, ok := Scale(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	s := strings.TrimPrefix(amount, "-")
+	negative := len(s) < len(amount)
+
+	whole, frac, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if len(frac) > scale {
+		if strings.Trim(frac[scale:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrPrecision, amount, scale, currency)
+		}
+		frac = frac[:scale]
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+	if negative {
+		n = -n
+	}
+	return Money{amount: n, currency: currency}, nil
+}
+
+// MustParse is Parse for constants and tests; it panics on error
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// FromRat rounds an exact rational amount to the minor unit of currency
+func FromRat(r *big.Rat, currency string, mode RoundingMode) (Money, error) {
+	scale, ok := Scale(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt64(pow10(scale)))
+	n, err := round(scaled, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: n, currency: currency}, nil
+}
+
+// round rounds r to an integer
+func round(r *big.Rat, mode RoundingMode) (int64, error) {
+	num, den := r.Num(), r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int)) // Truncates toward zero
+
+	if rem.Sign() != 0 && mode != RoundDown {
+		twice := new(big.Int).Lsh(new(big.Int).Abs(rem), 1)
+		c := twice.Cmp(den)
+		if c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)) {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%w: %s", ErrOverflow, r.FloatString(0))
+	}
+	return q.Int64(), nil
+}
+
+// Currency returns the ISO 4217 code of m
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// MinorUnits returns m in minor units, e.g. cents for USD
+func (m Money) MinorUnits() int64 {
+	return m.amount
+}
+
+// Rat returns m as an exact rational in major units
+func (m Money) Rat() *big.Rat {
+	scale, _ := Scale(m.currency)
+	return big.NewRat(m.amount, pow10(scale))
+}
+
+// String formats the amount with the currency's decimal places, e.g.
+// "1000.00" for USD and "1000" for JPY, without the currency code
+func (m Money) String() string {
+	scale, _ := Scale(m.currency)
+
+	sign := ""
+	abs := uint64(m.amount)
+	if m.amount < 0 {
+		sign = "-"
+		abs = uint64(-m.amount)
+	}
+
+	s := strconv.FormatUint(abs, 10)
+	if scale == 0 {
+		return sign + s
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+func (m Money) IsZero() bool     { return m.amount == 0 }
+func (m Money) IsPositive() bool { return m.amount > 0 }
+func (m Money) IsNegative() bool { return m.amount < 0 }
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{amount: -m.amount, currency: m.currency}
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.amount + o.amount
+	if (o.amount > 0 && sum < m.amount) || (o.amount < 0 && sum > m.amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, o)
+	}
+	return Money{amount: sum, currency: m.currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	diff := m.amount - o.amount
+	if (o.amount > 0 && diff > m.amount) || (o.amount < 0 && diff < m.amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, o)
+	}
+	return Money{amount: diff, currency: m.currency}, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o.
+// Both must be in the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Equal reports whether m and o are the same amount in the same currency
+func (m Money) Equal(o Money) bool {
+	return m == o
+}
+
+// Mul returns m * factor rounded to the minor unit, e.g. a 1.5% fee is
+// m.Mul(big.NewRat(15, 1000), money.RoundHalfUp)
+func (m Money) Mul(factor *big.Rat, mode RoundingMode) (Money, error) {
+	return FromRat(new(big.Rat).Mul(m.Rat(), factor), m.currency, mode)
+}
+
+// Allocate splits m into parts proportional to weights without losing a
+// minor unit: the parts always add up to m. Units left over by rounding go
+// to the parts with the largest remainders, earlier parts first on ties.
+func (m Money) Allocate(weights ...int64) ([]Money, error) {
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("%w: no parts", ErrInvalidAllocation)
+	}
+	total := new(big.Int)
+	for _, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("%w: negative weight %d", ErrInvalidAllocation, w)
+		}
+		total.Add(total, big.NewInt(w))
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("%w: weights sum to zero", ErrInvalidAllocation)
+	}
+
+	amount := big.NewInt(m.amount)
+	parts := make([]Money, len(weights))
+	remainders := make([]*big.Int, len(weights))
+	left := m.amount
+	for i, w := range weights {
+		share := new(big.Int).Mul(amount, big.NewInt(w))
+		share, rem := share.QuoRem(share, total, new(big.Int))
+
+		// |share| <= |m.amount|, so it fits
+		parts[i] = Money{amount: share.Int64(), currency: m.currency}
+		remainders[i] = rem.Abs(rem)
+		left -= share.Int64()
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+
+	unit := int64(1)
+	if left < 0 {
+		unit, left = -1, -left
+	}
+	for i := int64(0); i < left; i++ {
+		parts[order[i]].amount += unit
+	}
+	return parts, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: %d parts", ErrInvalidAllocation, n)
+	}
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return m.Allocate(weights...)
+}
+
+// Sum adds amounts in currency
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return nil
+}
+
+// moneyJSON is the JSON form of Money, with the amount as a string so
+// clients never parse it into a float
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount": "10.50", "currency": "USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency})
+}
+
+// UnmarshalJSON decodes {"amount": "10.50", "currency": "USD"}
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("failed to decode money: %w", err)
+	}
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
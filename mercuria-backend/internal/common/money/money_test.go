@@ -0,0 +1,280 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount        string
+		currency      string
+		expectedMinor int64
+		expectedErr   error
+	}{
+		{"1000.00", "USD", 100000, nil},
+		{"10.5", "EUR", 1050, nil},
+		{"0.01", "GBP", 1, nil},
+		{"-12.34", "USD", -1234, nil},
+		{"1000", "JPY", 1000, nil},
+		{"15000.50", "IDR", 1500050, nil},
+		{"10.500", "USD", 1050, nil},
+		{"500.0", "JPY", 500, nil},
+		{"10.505", "USD", 0, ErrPrecision},
+		{"500.5", "JPY", 0, ErrPrecision},
+		{"", "USD", 0, ErrInvalidAmount},
+		{"-", "USD", 0, ErrInvalidAmount},
+		{".50", "USD", 0, ErrInvalidAmount},
+		{"10.", "USD", 0, ErrInvalidAmount},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"+10", "USD", 0, ErrInvalidAmount},
+		{"1,000.00", "USD", 0, ErrInvalidAmount},
+		{"99999999999999999999", "USD", 0, ErrOverflow},
+		{"10.00", "CHF", 0, ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			m, err := Parse(tt.amount, tt.currency)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && m.MinorUnits() != tt.expectedMinor {
+				t.Errorf("Expected %d minor units, got %d", tt.expectedMinor, m.MinorUnits())
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		expected string
+	}{
+		{100000, "USD", "1000.00"},
+		{5, "USD", "0.05"},
+		{0, "EUR", "0.00"},
+		{-1234, "USD", "-12.34"},
+		{-5, "GBP", "-0.05"},
+		{1000, "JPY", "1000"},
+		{math.MinInt64, "JPY", "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			m, err := New(tt.minor, tt.currency)
+			if err != nil {
+				t.Fatalf("Failed to create money: %v", err)
+			}
+			if m.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, m.String())
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("10.10", "USD")
+	b := MustParse("0.20", "USD")
+
+	sum, err := a.Add(b)
+	if err != nil || sum.String() != "10.30" {
+		t.Errorf("Expected 10.30, got %s (%v)", sum, err)
+	}
+
+	diff, err := b.Sub(a)
+	if err != nil || diff.String() != "-9.90" {
+		t.Errorf("Expected -9.90, got %s (%v)", diff, err)
+	}
+
+	if c, err := a.Cmp(b); err != nil || c != 1 {
+		t.Errorf("Expected 10.10 > 0.20, got %d (%v)", c, err)
+	}
+
+	if _, err := a.Add(MustParse("1", "JPY")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := a.Cmp(MustParse("1", "JPY")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+
+	max, _ := New(math.MaxInt64, "USD")
+	if _, err := max.Add(MustParse("0.01", "USD")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	min, _ := New(math.MinInt64, "USD")
+	if _, err := min.Sub(MustParse("0.01", "USD")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+
+	total, err := Sum("EUR", MustParse("0.10", "EUR"), MustParse("0.20", "EUR"), MustParse("0.30", "EUR"))
+	if err != nil || total.String() != "0.60" {
+		t.Errorf("Expected 0.60, got %s (%v)", total, err)
+	}
+}
+
+func TestFromRat(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		currency string
+		mode     RoundingMode
+		expected string
+	}{
+		{"exact", "10.25", "USD", RoundHalfEven, "10.25"},
+		{"half even down", "0.125", "USD", RoundHalfEven, "0.12"},
+		{"half even up", "0.135", "USD", RoundHalfEven, "0.14"},
+		{"half up", "0.125", "USD", RoundHalfUp, "0.13"},
+		{"negative half up", "-0.125", "USD", RoundHalfUp, "-0.13"},
+		{"negative half even", "-0.125", "USD", RoundHalfEven, "-0.12"},
+		{"above half", "0.1251", "USD", RoundHalfEven, "0.13"},
+		{"down", "0.129", "USD", RoundDown, "0.12"},
+		{"negative down", "-0.129", "USD", RoundDown, "-0.12"},
+		{"JPY", "1234.5", "JPY", RoundHalfEven, "1234"},
+		{"JPY half up", "1234.5", "JPY", RoundHalfUp, "1235"},
+		{"one third", "1/3", "USD", RoundHalfEven, "0.33"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := new(big.Rat).SetString(tt.value)
+			m, err := FromRat(r, tt.currency, tt.mode)
+			if err != nil {
+				t.Fatalf("Failed to round: %v", err)
+			}
+			if m.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, m)
+			}
+		})
+	}
+
+	huge, _ := new(big.Rat).SetString("1e30")
+	if _, err := FromRat(huge, "USD", RoundHalfEven); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+}
+
+func TestMul(t *testing.T) {
+	// 1.5% fee on 33.33 is 0.49995
+	fee, err := MustParse("33.33", "USD").Mul(big.NewRat(15, 1000), RoundHalfUp)
+	if err != nil || fee.String() != "0.50" {
+		t.Errorf("Expected fee 0.50, got %s (%v)", fee, err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		weights  []int64
+		expected []string
+	}{
+		{"even", "100.00", "USD", []int64{1, 1}, []string{"50.00", "50.00"}},
+		{"thirds", "100.00", "USD", []int64{1, 1, 1}, []string{"33.34", "33.33", "33.33"}},
+		{"largest remainder", "0.05", "USD", []int64{3, 7}, []string{"0.02", "0.03"}},
+		{"fee split", "10.00", "EUR", []int64{70, 20, 10}, []string{"7.00", "2.00", "1.00"}},
+		{"zero weight gets nothing", "0.10", "USD", []int64{1, 0, 2}, []string{"0.03", "0.00", "0.07"}},
+		{"JPY", "1000", "JPY", []int64{1, 1, 1}, []string{"334", "333", "333"}},
+		{"negative", "-100.00", "USD", []int64{1, 1, 1}, []string{"-33.34", "-33.33", "-33.33"}},
+		{"more parts than units", "0.02", "USD", []int64{1, 1, 1}, []string{"0.01", "0.01", "0.00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := MustParse(tt.amount, tt.currency)
+			parts, err := m.Allocate(tt.weights...)
+			if err != nil {
+				t.Fatalf("Failed to allocate: %v", err)
+			}
+
+			total := Zero(tt.currency)
+			for i, part := range parts {
+				if part.String() != tt.expected[i] {
+					t.Errorf("Part %d: expected %s, got %s", i, tt.expected[i], part)
+				}
+				total, _ = total.Add(part)
+			}
+			if !total.Equal(m) {
+				t.Errorf("Expected parts to add up to %s, got %s", m, total)
+			}
+		})
+	}
+
+	m := MustParse("1.00", "USD")
+	for _, weights := range [][]int64{nil, {0, 0}, {1, -1}} {
+		if _, err := m.Allocate(weights...); !errors.Is(err, ErrInvalidAllocation) {
+			t.Errorf("Weights %v: expected ErrInvalidAllocation, got %v", weights, err)
+		}
+	}
+
+	parts, err := MustParse("10.00", "USD").Split(3)
+	if err != nil || len(parts) != 3 || parts[0].String() != "3.34" {
+		t.Errorf("Expected 3.34/3.33/3.33, got %v (%v)", parts, err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	m := MustParse("1500.5", "IDR")
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if string(data) != `{"amount":"1500.50","currency":"IDR"}` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	var decoded Money
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if !decoded.Equal(m) {
+		t.Errorf("Expected %s %s, got %s %s", m, m.Currency(), decoded, decoded.Currency())
+	}
+
+	for _, invalid := range []string{
+		`{"amount":"10.001","currency":"USD"}`,
+		`{"amount":10.5,"currency":"USD"}`,
+		`{"amount":"10","currency":"XXX"}`,
+	} {
+		if err := json.Unmarshal([]byte(invalid), &decoded); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestSQL(t *testing.T) {
+	value, err := MustParse("12.30", "USD").Value()
+	if err != nil || value != driver.Value("12.30") {
+		t.Errorf("Expected value 12.30, got %v (%v)", value, err)
+	}
+
+	// NUMERIC columns may have more decimal places than the currency
+	m := Zero("USD")
+	if err := m.Scan([]byte("12.3000")); err != nil || m.String() != "12.30" {
+		t.Errorf("Expected 12.30, got %s (%v)", m, err)
+	}
+
+	var unset Money
+	if err := unset.Scan([]byte("1.00")); err == nil {
+		t.Error("Expected an error scanning without a currency")
+	}
+
+	currency := "JPY"
+	var balance Money
+	if err := In(&balance, &currency).Scan(int64(500)); err != nil || balance.String() != "500" || balance.Currency() != "JPY" {
+		t.Errorf("Expected 500 JPY, got %s %s (%v)", balance, balance.Currency(), err)
+	}
+	if err := In(&balance, &currency).Scan(nil); err == nil {
+		t.Error("Expected an error scanning NULL")
+	}
+	if err := In(&balance, &currency).Scan(1.5); err == nil {
+		t.Error("Expected an error scanning a float")
+	}
+}
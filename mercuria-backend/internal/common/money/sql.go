@@ -0,0 +1,72 @@
+package money
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+)
+
+// Value stores the amount as a decimal string for a NUMERIC column. The
+// currency is stored in its own column.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC column into m, in the currency m already has. Use In
+// when the currency comes from a column of the same row.
+func (m *Money) Scan(src interface{}) error {
+	if m.currency == "" {
+		return fmt.Errorf("failed to scan money: currency not set")
+	}
+	parsed, err := parseColumn(src, m.currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// In scans a NUMERIC column into m in the currency scanned into currency by
+// an earlier column of the same row:
+//
+//	row.Scan(&w.Currency, money.In(&w.Balance, &w.Currency))
+func In(m *Money, currency *string) sql.Scanner {
+	return &inCurrency{m: m, currency: currency}
+}
+
+type inCurrency struct {
+	m        *Money
+	currency *string
+}
+
+func (s *inCurrency) Scan(src interface{}) error {
+	parsed, err := parseColumn(src, *s.currency)
+	if err != nil {
+		return err
+	}
+	*s.m = parsed
+	return nil
+}
+
+func parseColumn(src interface{}, currency string) (Money, error) {
+	var amount string
+	switch v := src.(type) {
+	case []byte:
+		amount = string(v)
+	case string:
+		amount = v
+	case int64:
+		amount = strconv.FormatInt(v, 10)
+	case nil:
+		return Money{}, fmt.Errorf("failed to scan money: NULL amount")
+	default:
+		return Money{}, fmt.Errorf("failed to scan money: unsupported type %T", src)
+	}
+
+	m, err := Parse(amount, currency)
+	if err != nil {
+		return Money{}, fmt.Errorf("failed to scan money: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,60 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Identity is the calling service, as named by its client certificate
+type Identity struct {
+	Name        string // Service name, e.g. "ledger"
+	SPIFFEID    string // Full SPIFFE ID, if the certificate has one
+	TrustDomain string // SPIFFE trust domain, if the certificate has one
+}
+
+// IdentityFromCertificate names the service a certificate was issued to.
+// In order of preference it uses a SPIFFE URI SAN (the last path segment of
+// spiffe://mercuria.local/service/ledger), the subject CN, or the first label
+// of the first DNS SAN (ledger.mercuria.internal).
+func IdentityFromCertificate(cert *x509.Certificate) (Identity, error) {
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+
+		name := path.Base(uri.Path)
+		if uri.Host == "" || name == "/" || name == "." {
+			return Identity{}, fmt.Errorf("invalid SPIFFE ID: %s", uri)
+		}
+
+		return Identity{
+			Name:        name,
+			SPIFFEID:    uri.String(),
+			TrustDomain: uri.Host,
+		}, nil
+	}
+
+	if cert.Subject.CommonName != "" {
+		return Identity{Name: cert.Subject.CommonName}, nil
+	}
+
+	if len(cert.DNSNames) > 0 {
+		name, _, _ := strings.Cut(cert.DNSNames[0], ".")
+		if name != "" {
+			return Identity{Name: name}, nil
+		}
+	}
+
+	return Identity{}, fmt.Errorf("certificate has no service identity")
+}
+
+// PeerIdentity names the service on the other end of a verified connection
+func PeerIdentity(state *tls.ConnectionState) (Identity, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return Identity{}, fmt.Errorf("no verified client certificate")
+	}
+	return IdentityFromCertificate(state.VerifiedChains[0][0])
+}
@@ -0,0 +1,310 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+// DefaultReloadInterval is how often Watch checks the certificate files
+const DefaultReloadInterval = 30 * time.Second
+
+// CertInfo describes the loaded certificate, e.g. for health checks
+type CertInfo struct {
+	Subject   string
+	Serial    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	LoadedAt  time.Time
+}
+
+// ExpiresIn returns how long until the certificate expires
+func (i CertInfo) ExpiresIn() time.Duration {
+	return time.Until(i.NotAfter)
+}
+
+// bundle is one consistent set of files; it is swapped as a whole so a
+// handshake never sees a new cert with an old CA, or the other way round
+type bundle struct {
+	server *tls.Certificate
+	client *tls.Certificate
+	caPool *x509.CertPool
+	info   CertInfo
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertManager serves the certificates of a Config and reloads them when the
+// files change on disk, so short-lived certificates can be rotated without
+// restarting the service. Use its ServerTLSConfig and ClientTLSConfig in
+// place of the Config methods of the same name.
+type CertManager struct {
+	cfg    *Config
+	logger *logger.Logger
+
+	current atomic.Pointer[bundle]
+
+	mu     sync.Mutex // Serializes reloads
+	stamps map[string]fileStamp
+}
+
+// NewCertManager loads the certificates of cfg. Call Watch to pick up changes.
+func NewCertManager(cfg *Config, log *logger.Logger) (*CertManager, error) {
+	m := &CertManager{
+		cfg:    cfg,
+		logger: log,
+		stamps: map[string]fileStamp{},
+	}
+
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *CertManager) files() []string {
+	files := []string{m.cfg.CACert, m.cfg.ServerCert, m.cfg.ServerKey}
+	if m.cfg.ClientCert != "" {
+		files = append(files, m.cfg.ClientCert, m.cfg.ClientKey)
+	}
+	return files
+}
+
+// Reload reads the certificate files and swaps them in. On error the
+// previous certificates stay in use.
+func (m *CertManager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stamps, err := m.stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	m.current.Store(b)
+	m.stamps = stamps
+	return nil
+}
+
+func (m *CertManager) load() (*bundle, error) {
+	caCert, err := os.ReadFile(m.cfg.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA cert: %w", err)
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse CA cert")
+	}
+
+	server, err := tls.LoadX509KeyPair(m.cfg.ServerCert, m.cfg.ServerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server cert: %w", err)
+	}
+
+	// Services without a dedicated client certificate present the server one
+	client := &server
+	if m.cfg.ClientCert != "" {
+		clientCert, err := tls.LoadX509KeyPair(m.cfg.ClientCert, m.cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client cert: %w", err)
+		}
+		client = &clientCert
+	}
+
+	leaf := server.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(server.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("failed to parse server cert: %w", err)
+		}
+	}
+
+	return &bundle{
+		server: &server,
+		client: client,
+		caPool: caPool,
+		info: CertInfo{
+			Subject:   leaf.Subject.String(),
+			Serial:    leaf.SerialNumber.String(),
+			NotBefore: leaf.NotBefore,
+			NotAfter:  leaf.NotAfter,
+			LoadedAt:  time.Now(),
+		},
+	}, nil
+}
+
+func (m *CertManager) stat() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	for _, path := range m.files() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		stamps[path] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stamps, nil
+}
+
+func (m *CertManager) changed() bool {
+	stamps, err := m.stat()
+	if err != nil {
+		// Files are mid-rotation or gone; keep serving what we have
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for path, stamp := range stamps {
+		if m.stamps[path] != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch polls the certificate files every interval (the Config's
+// ReloadInterval if zero) and reloads them when they change, until ctx is
+// done. Polling rather than file events also catches the symlink swaps used
+// by Kubernetes secret volumes.
+func (m *CertManager) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = m.cfg.ReloadInterval
+	}
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.changed() {
+				continue
+			}
+
+			if err := m.Reload(); err != nil {
+				m.logger.Errorf("Failed to reload mTLS certificates: %v", err)
+				continue
+			}
+
+			info := m.Info()
+			m.logger.Infof("Reloaded mTLS certificate %s (serial %s, expires %s)",
+				info.Subject, info.Serial, info.NotAfter.Format(time.RFC3339))
+		}
+	}
+}
+
+// Info describes the server certificate currently in use
+func (m *CertManager) Info() CertInfo {
+	return m.current.Load().info
+}
+
+// GetCertificate serves the current server certificate
+func (m *CertManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.current.Load().server, nil
+}
+
+// GetClientCertificate presents the current client certificate
+func (m *CertManager) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return m.current.Load().client, nil
+}
+
+// GetConfigForClient returns a server config with the current CA pool, since
+// tls.Config has no callback for ClientCAs
+func (m *CertManager) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	b := m.current.Load()
+
+	cfg := m.baseServerConfig()
+	cfg.Certificates = []tls.Certificate{*b.server}
+	cfg.ClientCAs = b.caPool
+	return cfg, nil
+}
+
+func (m *CertManager) baseServerConfig() *tls.Config {
+	return &tls.Config{
+		ClientAuth:            tls.RequireAndVerifyClientCert,
+		VerifyPeerCertificate: m.cfg.verifyPeerCertificate,
+		MinVersion:            tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{
+			tls.CurveP521,
+			tls.CurveP384,
+			tls.CurveP256,
+		},
+	}
+}
+
+// ServerTLSConfig creates a TLS config for the HTTP server that always uses
+// the current certificates
+func (m *CertManager) ServerTLSConfig() *tls.Config {
+	cfg := m.baseServerConfig()
+	cfg.GetCertificate = m.GetCertificate
+	cfg.GetConfigForClient = m.GetConfigForClient
+	return cfg
+}
+
+// ClientTLSConfig creates a TLS config for HTTP clients that always uses the
+// current certificates
+func (m *CertManager) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: m.GetClientCertificate,
+
+		// RootCAs cannot be swapped on a live config, so the built-in
+		// verification is replaced by VerifyConnection, which checks the
+		// chain and host name against the current CA pool
+		InsecureSkipVerify: true,
+		VerifyConnection:   m.verifyServer,
+
+		MinVersion: tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{
+			tls.CurveP521,
+			tls.CurveP384,
+			tls.CurveP256,
+		},
+	}
+}
+
+// verifyServer checks the server chain against the current CA pool and the
+// server identity against the Config, like the server does for clients. The
+// host name is checked too when known; connections dialed by IP carry no
+// server name, so for those the identity check is what binds the peer.
+func (m *CertManager) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         m.current.Load().caPool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	chains, err := cs.PeerCertificates[0].Verify(opts)
+	if err != nil {
+		return fmt.Errorf("failed to verify server certificate: %w", err)
+	}
+
+	return m.cfg.verifyPeerCertificate(nil, chains)
+}
@@ -5,8 +5,12 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"time"
 )
 
+// DefaultOrganization is the organization expected in peer certificates
+const DefaultOrganization = "Mercuria"
+
 // Config holds mTLS configuration
 type Config struct {
 	Enabled    bool
@@ -15,6 +19,11 @@ type Config struct {
 	ServerKey  string // Path to server private key
 	ClientCert string // Path to client certificate (for outgoing requests)
 	ClientKey  string // Path to client private key (for outgoing requests)
+
+	TrustDomain  string // Required SPIFFE trust domain of peers, if set
+	Organization string // Required organization of peers, if set
+
+	ReloadInterval time.Duration // How often CertManager checks the files for changes
 }
 
 // LoadFromEnv loads mTLS configuration from environment variables
@@ -28,6 +37,11 @@ func LoadFromEnv() *Config {
 		ServerKey:  os.Getenv("MTLS_SERVER_KEY"),
 		ClientCert: os.Getenv("MTLS_CLIENT_CERT"),
 		ClientKey:  os.Getenv("MTLS_CLIENT_KEY"),
+
+		TrustDomain:  os.Getenv("MTLS_TRUST_DOMAIN"),
+		Organization: getEnv("MTLS_ORGANIZATION", DefaultOrganization),
+
+		ReloadInterval: getEnvAsDuration("MTLS_RELOAD_INTERVAL", DefaultReloadInterval),
 	}
 }
 
@@ -64,6 +78,9 @@ func (c *Config) ServerTLSConfig() (*tls.Config, error) {
 		// Client certificate validation
 		ClientAuth: tls.RequireAndVerifyClientCert,
 		ClientCAs:  caCertPool,
+
+		// Peer identity validation, on top of chain verification
+		VerifyPeerCertificate: c.verifyPeerCertificate,
 		
 		// Security settings
 		MinVersion: tls.VersionTLS13,
@@ -128,23 +145,61 @@ func (c *Config) ClientTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-// VerifyPeerCertificate validates the peer's certificate
-// This can be used for additional custom validation
+// VerifyPeerCertificate validates the peer's certificate against the default
+// organization. ServerTLSConfig wires in the equivalent check for its Config.
 func VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
-	// Custom validation logic can go here
-	// For example, check CN, organization, etc.
-	
-	if len(verifiedChains) == 0 {
+	c := &Config{Organization: DefaultOrganization}
+	return c.verifyPeerCertificate(rawCerts, verifiedChains)
+}
+
+// verifyPeerCertificate runs after chain verification and rejects peers
+// outside our organization or trust domain, or without a service identity
+func (c *Config) verifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
 		return fmt.Errorf("no verified certificate chains")
 	}
 
 	// Get the peer certificate
 	cert := verifiedChains[0][0]
-	
-	// Example: Validate organization
-	if len(cert.Subject.Organization) > 0 && cert.Subject.Organization[0] != "Mercuria" {
-		return fmt.Errorf("invalid organization: %s", cert.Subject.Organization[0])
+
+	if c.Organization != "" {
+		if len(cert.Subject.Organization) == 0 {
+			return fmt.Errorf("certificate has no organization, expected %s", c.Organization)
+		}
+		if cert.Subject.Organization[0] != c.Organization {
+			return fmt.Errorf("invalid organization: %s", cert.Subject.Organization[0])
+		}
+	}
+
+	identity, err := IdentityFromCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	// With a trust domain configured, peers must prove membership with a
+	// SPIFFE ID; a CN-only certificate could have been issued for anything
+	if c.TrustDomain != "" {
+		if identity.SPIFFEID == "" {
+			return fmt.Errorf("certificate has no SPIFFE ID for trust domain %s", c.TrustDomain)
+		}
+		if identity.TrustDomain != c.TrustDomain {
+			return fmt.Errorf("invalid trust domain: %s", identity.TrustDomain)
+		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
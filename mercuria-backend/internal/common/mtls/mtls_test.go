@@ -0,0 +1,285 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/devca"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+func newTestCertificate(t *testing.T, subject pkix.Name, dnsNames []string, uris ...string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse URI: %v", err)
+		}
+		template.URIs = append(template.URIs, u)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestIdentityFromCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "SPIFFE ID wins",
+			cert:     newTestCertificate(t, pkix.Name{CommonName: "ignored"}, nil, "spiffe://mercuria.local/service/ledger"),
+			expected: "ledger",
+		},
+		{
+			name:     "common name",
+			cert:     newTestCertificate(t, pkix.Name{CommonName: "wallet"}, []string{"ignored.mercuria.internal"}),
+			expected: "wallet",
+		},
+		{
+			name:     "DNS SAN",
+			cert:     newTestCertificate(t, pkix.Name{}, []string{"transaction.mercuria.internal"}),
+			expected: "transaction",
+		},
+		{
+			name:    "no identity",
+			cert:    newTestCertificate(t, pkix.Name{}, nil),
+			wantErr: true,
+		},
+		{
+			name:    "SPIFFE ID without path",
+			cert:    newTestCertificate(t, pkix.Name{}, nil, "spiffe://mercuria.local"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := IdentityFromCertificate(tt.cert)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got identity %+v", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IdentityFromCertificate failed: %v", err)
+			}
+			if identity.Name != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, identity.Name)
+			}
+		})
+	}
+}
+
+func TestVerifyPeerCertificate(t *testing.T) {
+	cfg := &Config{TrustDomain: "mercuria.local", Organization: DefaultOrganization}
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			cert: newTestCertificate(t, pkix.Name{Organization: []string{"Mercuria"}}, nil, "spiffe://mercuria.local/service/ledger"),
+		},
+		{
+			name:    "foreign trust domain",
+			cert:    newTestCertificate(t, pkix.Name{Organization: []string{"Mercuria"}}, nil, "spiffe://evil.example/service/ledger"),
+			wantErr: true,
+		},
+		{
+			name:    "foreign organization",
+			cert:    newTestCertificate(t, pkix.Name{CommonName: "ledger", Organization: []string{"Evil"}}, nil),
+			wantErr: true,
+		},
+		{
+			name:    "no identity",
+			cert:    newTestCertificate(t, pkix.Name{Organization: []string{"Mercuria"}}, nil),
+			wantErr: true,
+		},
+		{
+			name:    "no organization",
+			cert:    newTestCertificate(t, pkix.Name{}, nil, "spiffe://mercuria.local/service/ledger"),
+			wantErr: true,
+		},
+		{
+			name:    "CN only with trust domain set",
+			cert:    newTestCertificate(t, pkix.Name{CommonName: "ledger", Organization: []string{"Mercuria"}}, nil),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfg.verifyPeerCertificate(nil, [][]*x509.Certificate{{tt.cert}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Without a trust domain, CN-based identities are still accepted
+	noDomain := &Config{Organization: DefaultOrganization}
+	cert := newTestCertificate(t, pkix.Name{CommonName: "ledger", Organization: []string{"Mercuria"}}, nil)
+	if err := noDomain.verifyPeerCertificate(nil, [][]*x509.Certificate{{cert}}); err != nil {
+		t.Errorf("Expected CN-only certificate to be accepted without a trust domain, got %v", err)
+	}
+}
+
+// writeTestPKI writes a fresh CA plus a certificate for "wallet" into dir and
+// returns its serial number
+func writeTestPKI(t *testing.T, dir string) (*Config, string) {
+	t.Helper()
+
+	ca, err := devca.New(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	cert, err := ca.Issue(devca.IssueOptions{Service: "wallet", Lifetime: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	cfg := &Config{
+		Enabled:      true,
+		CACert:       filepath.Join(dir, "ca.crt"),
+		ServerCert:   filepath.Join(dir, "service.crt"),
+		ServerKey:    filepath.Join(dir, "service.key"),
+		Organization: DefaultOrganization,
+	}
+
+	if err := ca.WriteFiles(cfg.CACert, filepath.Join(dir, "ca.key")); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+	if err := cert.WriteFiles(cfg.ServerCert, cfg.ServerKey); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	return cfg, cert.Cert.SerialNumber.String()
+}
+
+func TestCertManagerReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg, first := writeTestPKI(t, dir)
+
+	manager, err := NewCertManager(cfg, logger.New("test"))
+	if err != nil {
+		t.Fatalf("NewCertManager failed: %v", err)
+	}
+	if manager.Info().Serial != first {
+		t.Fatalf("Expected serial %s, got %s", first, manager.Info().Serial)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = manager.ServerTLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: manager.ClientTLSConfig()}}
+	get := func() (string, error) {
+		client.CloseIdleConnections()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.String(), nil
+	}
+
+	serial, err := get()
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	if serial != first {
+		t.Errorf("Expected serial %s, got %s", first, serial)
+	}
+
+	// Rotate to a new CA and certificate; mtimes may not move within the
+	// filesystem's resolution, so make the change visible explicitly
+	_, second := writeTestPKI(t, dir)
+	future := time.Now().Add(time.Minute)
+	for _, path := range manager.files() {
+		os.Chtimes(path, future, future)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.Watch(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for manager.Info().Serial != second && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if manager.Info().Serial != second {
+		t.Fatalf("Expected reload to serial %s, got %s", second, manager.Info().Serial)
+	}
+
+	serial, err = get()
+	if err != nil {
+		t.Fatalf("Handshake after rotation failed: %v", err)
+	}
+	if serial != second {
+		t.Errorf("Expected serial %s after rotation, got %s", second, serial)
+	}
+}
+
+func TestCertManagerKeepsCertsOnBadReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg, serial := writeTestPKI(t, dir)
+
+	manager, err := NewCertManager(cfg, logger.New("test"))
+	if err != nil {
+		t.Fatalf("NewCertManager failed: %v", err)
+	}
+
+	if err := os.WriteFile(cfg.ServerKey, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	if err := manager.Reload(); err == nil {
+		t.Fatal("Expected reload of a corrupt key to fail")
+	}
+	if manager.Info().Serial != serial {
+		t.Errorf("Expected previous certificate to stay in use, got serial %s", manager.Info().Serial)
+	}
+}
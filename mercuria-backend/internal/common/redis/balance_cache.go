@@ -0,0 +1,226 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+)
+
+// CachedBalance is a wallet balance snapshot tagged with the wallet row version
+// it was read from. Versions only ever grow, so they order cache writes.
+type CachedBalance struct {
+	WalletID string
+	Balance  string
+	Version  int64
+}
+
+// BalanceLoader reads the committed balance of a wallet from the database
+type BalanceLoader func(ctx context.Context, walletID string) (*CachedBalance, error)
+
+// ErrBalanceNotFound is returned by Get when the loader finds no balance
+var ErrBalanceNotFound = errors.New("wallet balance not found")
+
+// defaultBalanceLoadTimeout bounds a load shared by concurrent misses
+const defaultBalanceLoadTimeout = 5 * time.Second
+
+// BalanceUpdatedEvent is the payload of a wallet.balance_updated event
+type BalanceUpdatedEvent struct {
+	WalletID string `json:"wallet_id"`
+	Balance  string `json:"balance"`
+	Version  int64  `json:"version"`
+}
+
+// setBalanceScript stores a balance only if its version is newer than the
+// cached one. A tombstone (version without balance) left by an invalidation
+// accepts the same version, since that is what a post-commit reload returns.
+var setBalanceScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'version')
+if cur then
+	cur = tonumber(cur)
+	local v = tonumber(ARGV[2])
+	if v < cur then
+		return 0
+	end
+	if v == cur and redis.call('HEXISTS', KEYS[1], 'balance') == 1 then
+		return 0
+	end
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'balance', ARGV[1], 'version', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// invalidateBalanceScript replaces anything older than the given version with a
+// tombstone, so a loader that read the database before the commit cannot put
+// the stale balance back.
+var invalidateBalanceScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'version')
+if cur and tonumber(cur) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'version', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// BalanceCache is a cache-aside wallet balance cache with versioned writes.
+// Reads fall through to the loader on a miss, with concurrent misses for the
+// same wallet collapsed into a single load.
+type BalanceCache struct {
+	client      *Client
+	loader      BalanceLoader
+	ttl         time.Duration
+	loadTimeout time.Duration
+	group       singleflight.Group
+}
+
+func NewBalanceCache(client *Client, loader BalanceLoader, ttl time.Duration) *BalanceCache {
+	return &BalanceCache{
+		client:      client,
+		loader:      loader,
+		ttl:         ttl,
+		loadTimeout: defaultBalanceLoadTimeout,
+	}
+}
+
+func (bc *BalanceCache) key(walletID string) string {
+	return bc.client.keys.Shared("wallet", "balance", "versioned", Tag(walletID))
+}
+
+// Get returns the wallet balance, loading it from the database on a cache miss.
+// The load is shared with concurrent misses for the wallet, so it runs detached
+// from ctx under its own timeout; ctx only bounds how long this caller waits.
+func (bc *BalanceCache) Get(ctx context.Context, walletID string) (*CachedBalance, error) {
+	cached, err := bc.Peek(ctx, walletID)
+	if err != nil {
+		// Redis trouble should not take balance reads down with it
+		bc.client.logger.Warnf("Balance cache read failed for wallet %s: %v", walletID, err)
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	ch := bc.group.DoChan(walletID, func() (interface{}, error) {
+		// One caller giving up must not fail the others sharing this load
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bc.loadTimeout)
+		defer cancel()
+
+		loaded, err := bc.loader(loadCtx, walletID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load balance: %w", err)
+		}
+		if loaded == nil {
+			return nil, fmt.Errorf("%w: %s", ErrBalanceNotFound, walletID)
+		}
+
+		if _, err := bc.Set(loadCtx, loaded); err != nil {
+			bc.client.logger.Warnf("Failed to populate balance cache for wallet %s: %v", walletID, err)
+		}
+
+		return loaded, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*CachedBalance), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Peek returns the cached balance without touching the database.
+// It returns nil if the wallet is not cached or has been invalidated.
+func (bc *BalanceCache) Peek(ctx context.Context, walletID string) (*CachedBalance, error) {
+	fields, err := bc.client.HGetAll(ctx, bc.key(walletID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached balance: %w", err)
+	}
+
+	balance, ok := fields["balance"]
+	if !ok {
+		return nil, nil // Not found or tombstone
+	}
+
+	version, err := strconv.ParseInt(fields["version"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached balance version: %w", err)
+	}
+
+	return &CachedBalance{
+		WalletID: walletID,
+		Balance:  balance,
+		Version:  version,
+	}, nil
+}
+
+// Set caches a balance if it is newer than the cached one.
+// It reports whether the cache was updated.
+func (bc *BalanceCache) Set(ctx context.Context, b *CachedBalance) (bool, error) {
+	stored, err := setBalanceScript.Run(
+		ctx,
+		bc.client,
+		[]string{bc.key(b.WalletID)},
+		b.Balance,
+		b.Version,
+		bc.ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to cache balance: %w", err)
+	}
+
+	return stored == 1, nil
+}
+
+// Invalidate drops cached balances older than version.
+// NOTE: Call this after the transaction that produced version has committed,
+// otherwise a concurrent reader may cache the pre-commit balance again.
+// A version of zero drops the entry unconditionally.
+func (bc *BalanceCache) Invalidate(ctx context.Context, walletID string, version int64) error {
+	key := bc.key(walletID)
+
+	if version <= 0 {
+		if err := bc.client.Del(ctx, key).Err(); err != nil {
+			return fmt.Errorf("failed to invalidate balance: %w", err)
+		}
+		return nil
+	}
+
+	err := invalidateBalanceScript.Run(ctx, bc.client, []string{key}, version, bc.ttl.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to invalidate balance: %w", err)
+	}
+
+	bc.client.logger.Debugf("Balance cache invalidated: wallet %s version %d", walletID, version)
+	return nil
+}
+
+// HandleBalanceUpdated invalidates the cache from a wallet.balance_updated event.
+// It matches kafka.EventHandler, so it can be passed straight to Consumer.Consume.
+// Events are published by the outbox after commit, which makes them the right
+// trigger for invalidation.
+func (bc *BalanceCache) HandleBalanceUpdated(ctx context.Context, key []byte, value []byte) error {
+	var event BalanceUpdatedEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal balance updated event: %w", err)
+	}
+
+	if event.WalletID == "" {
+		event.WalletID = string(key)
+	}
+	if event.WalletID == "" {
+		return fmt.Errorf("balance updated event has no wallet id")
+	}
+
+	return bc.Invalidate(ctx, event.WalletID, event.Version)
+}
@@ -0,0 +1,197 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBalanceCacheSingleFlight(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	walletID := "wallet-cache-sf"
+
+	var loads int32
+	cache := NewBalanceCache(client, func(ctx context.Context, id string) (*CachedBalance, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return &CachedBalance{WalletID: id, Balance: "100.00", Version: 1}, nil
+	}, time.Minute)
+	defer client.Del(ctx, cache.key(walletID))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := cache.Get(ctx, walletID)
+			if err != nil {
+				t.Errorf("Get failed: %v", err)
+				return
+			}
+			if b.Balance != "100.00" {
+				t.Errorf("Expected balance 100.00, got %s", b.Balance)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Expected 1 load, got %d", n)
+	}
+
+	// Served from cache now
+	if _, err := cache.Get(ctx, walletID); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Expected cache hit, loader called %d times", n)
+	}
+}
+
+func TestBalanceCacheCancelledCaller(t *testing.T) {
+	client := newTestClient(t)
+	walletID := "wallet-cache-cancel"
+
+	release := make(chan struct{})
+	var loads int32
+	cache := NewBalanceCache(client, func(ctx context.Context, id string) (*CachedBalance, error) {
+		atomic.AddInt32(&loads, 1)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return &CachedBalance{WalletID: id, Balance: "100.00", Version: 1}, nil
+	}, time.Minute)
+	defer client.Del(context.Background(), cache.key(walletID))
+
+	// The first caller starts the load, then gives up
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(first, walletID)
+		firstErr <- err
+	}()
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiterErr := make(chan error, 1)
+	go func() {
+		b, err := cache.Get(context.Background(), walletID)
+		if err == nil && b.Balance != "100.00" {
+			t.Errorf("Expected balance 100.00, got %s", b.Balance)
+		}
+		waiterErr <- err
+	}()
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("Expected the cancelled caller to get context.Canceled, got %v", err)
+	}
+
+	// Give the waiter time to join the load
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-waiterErr; err != nil {
+		t.Errorf("Expected the waiter to get the balance, got %v", err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Expected 1 load, got %d", n)
+	}
+}
+
+func TestBalanceCacheNotFound(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	cache := NewBalanceCache(client, func(ctx context.Context, id string) (*CachedBalance, error) {
+		return nil, nil
+	}, time.Minute)
+	defer client.Del(ctx, cache.key("wallet-cache-missing"))
+
+	if _, err := cache.Get(ctx, "wallet-cache-missing"); !errors.Is(err, ErrBalanceNotFound) {
+		t.Errorf("Expected ErrBalanceNotFound, got %v", err)
+	}
+}
+
+func TestBalanceCacheVersioning(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	walletID := "wallet-cache-version"
+
+	cache := NewBalanceCache(client, nil, time.Minute)
+	defer client.Del(ctx, cache.key(walletID))
+
+	stored, err := cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "50.00", Version: 5})
+	if err != nil || !stored {
+		t.Fatalf("Expected first write to be stored, got %v, %v", stored, err)
+	}
+
+	// Older version must not replace a newer one
+	stored, err = cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "40.00", Version: 4})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if stored {
+		t.Error("Older version should not replace cached balance")
+	}
+
+	stored, err = cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "60.00", Version: 6})
+	if err != nil || !stored {
+		t.Fatalf("Expected newer write to be stored, got %v, %v", stored, err)
+	}
+
+	b, err := cache.Peek(ctx, walletID)
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if b == nil || b.Balance != "60.00" || b.Version != 6 {
+		t.Errorf("Expected balance 60.00 at version 6, got %+v", b)
+	}
+}
+
+func TestBalanceCacheInvalidation(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	walletID := "wallet-cache-invalidate"
+
+	cache := NewBalanceCache(client, nil, time.Minute)
+	defer client.Del(ctx, cache.key(walletID))
+
+	if _, err := cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "10.00", Version: 1}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	event := []byte(`{"wallet_id":"` + walletID + `","balance":"15.00","version":2}`)
+	if err := cache.HandleBalanceUpdated(ctx, []byte(walletID), event); err != nil {
+		t.Fatalf("HandleBalanceUpdated failed: %v", err)
+	}
+
+	b, err := cache.Peek(ctx, walletID)
+	if err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if b != nil {
+		t.Errorf("Expected cache miss after invalidation, got %+v", b)
+	}
+
+	// A loader that read before the commit must not repopulate the cache
+	stored, err := cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "10.00", Version: 1})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if stored {
+		t.Error("Stale balance should be rejected after invalidation")
+	}
+
+	// A reload of the committed version is accepted
+	stored, err = cache.Set(ctx, &CachedBalance{WalletID: walletID, Balance: "15.00", Version: 2})
+	if err != nil || !stored {
+		t.Fatalf("Expected committed version to be stored, got %v, %v", stored, err)
+	}
+}
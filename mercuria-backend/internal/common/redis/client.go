@@ -2,41 +2,116 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/kmassidik/mercuria/internal/common/config"
 	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
 )
 
 type Client struct {
-	*redis.Client
+	redis.UniversalClient
+	keys   KeyBuilder
 	logger *logger.Logger
 }
 
+// Connect creates a standalone, Sentinel or cluster client depending on cfg.Mode.
+// Commands run inside a trace are recorded as client spans.
+// NOTE: Cluster mode only supports DB 0, and multi-key commands only work when
+// every key hashes to the same slot. Helpers in this package wrap the shared part
+// of their keys in a {hash tag} for that reason.
 func Connect(cfg config.RedisConfig, log *logger.Logger) (*Client, error) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		Password:     cfg.Password,
-		DB:           cfg.DB,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-		PoolSize:     10,
-		MinIdleConns: 5,
-	})
+	addrs := cfg.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            addrs,
+		Password:         cfg.Password,
+		DB:               cfg.DB,
+		MasterName:       cfg.MasterName,
+		SentinelPassword: cfg.SentinelPassword,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+	}
+
+	if cfg.TLSEnabled {
+		tlsConfig, err := redisTLSConfig(cfg.TLSCACert)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	var rdb redis.UniversalClient
+	switch cfg.Mode {
+	case config.RedisModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires a master name")
+		}
+		rdb = redis.NewFailoverClient(opts.Failover())
+	case config.RedisModeCluster:
+		rdb = redis.NewClusterClient(opts.Cluster())
+	case config.RedisModeStandalone, "":
+		rdb = redis.NewClient(opts.Simple())
+	default:
+		return nil, fmt.Errorf("unknown redis mode: %s", cfg.Mode)
+	}
+
+	rdb.AddHook(tracingHook{})
+	if err := metrics.Register(poolStatsCollector{client: rdb}); err != nil {
+		log.Warnf("Failed to register Redis pool metrics: %v", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	log.Info("Connected to Redis")
+	mode := cfg.Mode
+	if mode == "" {
+		mode = config.RedisModeStandalone
+	}
+	log.Infof("Connected to Redis (%s)", mode)
+
+	return &Client{
+		UniversalClient: rdb,
+		keys:            NewKeyBuilder(cfg.KeyPrefix, cfg.Service),
+		logger:          log,
+	}, nil
+}
+
+func redisTLSConfig(caCertPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caCertPath == "" {
+		return tlsConfig, nil // Use system roots
+	}
+
+	caCert, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Redis CA cert: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse Redis CA cert")
+	}
+	tlsConfig.RootCAs = pool
 
-	return &Client{Client: rdb, logger: log}, nil
+	return tlsConfig, nil
 }
 
 func (c *Client) Health(ctx context.Context) error {
@@ -44,22 +119,26 @@ func (c *Client) Health(ctx context.Context) error {
 }
 
 func (c *Client) AcquireLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
-	lockKey := fmt.Sprintf("lock:%s", key)
+	lockKey := c.keys.Shared("lock", Tag(key))
 	
 	ok, err := c.SetNX(ctx, lockKey, "locked", ttl).Result()
 	if err != nil {
+		metrics.RedisLockAcquisitions.WithLabelValues("error").Inc()
 		return false, fmt.Errorf("failed to acquire lock: %w", err)
 	}
 
 	if ok {
+		metrics.RedisLockAcquisitions.WithLabelValues("acquired").Inc()
 		c.logger.Debugf("Lock acquired: %s", lockKey)
+	} else {
+		metrics.RedisLockAcquisitions.WithLabelValues("contended").Inc()
 	}
 
 	return ok, nil
 }
 
 func (c *Client) ReleaseLock(ctx context.Context, key string) error {
-	lockKey := fmt.Sprintf("lock:%s", key)
+	lockKey := c.keys.Shared("lock", Tag(key))
 	
 	err := c.Del(ctx, lockKey).Err()
 	if err != nil {
@@ -71,7 +150,7 @@ func (c *Client) ReleaseLock(ctx context.Context, key string) error {
 }
 
 func (c *Client) CheckIdempotency(ctx context.Context, key string) (bool, error) {
-	idempotencyKey := fmt.Sprintf("idempotency:%s", key)
+	idempotencyKey := c.keys.Key("idempotency", Tag(key))
 	
 	exists, err := c.Exists(ctx, idempotencyKey).Result()
 	if err != nil {
@@ -82,7 +161,7 @@ func (c *Client) CheckIdempotency(ctx context.Context, key string) (bool, error)
 }
 
 func (c *Client) SetIdempotency(ctx context.Context, key string, ttl time.Duration) error {
-	idempotencyKey := fmt.Sprintf("idempotency:%s", key)
+	idempotencyKey := c.keys.Key("idempotency", Tag(key))
 	
 	err := c.Set(ctx, idempotencyKey, "used", ttl).Err()
 	if err != nil {
@@ -93,54 +172,30 @@ func (c *Client) SetIdempotency(ctx context.Context, key string, ttl time.Durati
 	return nil
 }
 
-func (c *Client) CacheWalletBalance(ctx context.Context, walletID string, balance string, ttl time.Duration) error {
-	key := fmt.Sprintf("wallet:balance:%s", walletID)
-	
-	err := c.Set(ctx, key, balance, ttl).Err()
-	if err != nil {
-		return fmt.Errorf("failed to cache balance: %w", err)
-	}
-
-	return nil
-}
-
-func (c *Client) GetCachedWalletBalance(ctx context.Context, walletID string) (string, error) {
-	key := fmt.Sprintf("wallet:balance:%s", walletID)
-	
-	balance, err := c.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return "", nil // Not found
-	}
-	if err != nil {
-		return "", fmt.Errorf("failed to get cached balance: %w", err)
-	}
-
-	return balance, nil
-}
-
-func (c *Client) InvalidateWalletBalance(ctx context.Context, walletID string) error {
-	key := fmt.Sprintf("wallet:balance:%s", walletID)
-	
-	return c.Del(ctx, key).Err()
-}
+// incrementCounterScript sets the TTL only when the counter has none, so a
+// counter that is hit constantly still expires ttl after its first increment
+var incrementCounterScript = redis.NewScript(`
+local v = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return v
+`)
 
 func (c *Client) IncrementCounter(ctx context.Context, key string, ttl time.Duration) error {
-	analyticsKey := fmt.Sprintf("analytics:%s", key)
-	
-	// Increment counter
-	err := c.Incr(ctx, analyticsKey).Err()
+	analyticsKey := c.keys.Key("analytics", key)
+
+	// Increment counter and set expiration on first increment, atomically
+	err := incrementCounterScript.Run(ctx, c, []string{analyticsKey}, ttl.Milliseconds()).Err()
 	if err != nil {
 		return fmt.Errorf("failed to increment counter: %w", err)
 	}
 
-	// Set expiration if this is the first increment
-	c.Expire(ctx, analyticsKey, ttl)
-
 	return nil
 }
 
 func (c *Client) GetCounter(ctx context.Context, key string) (int64, error) {
-	analyticsKey := fmt.Sprintf("analytics:%s", key)
+	analyticsKey := c.keys.Key("analytics", key)
 	
 	val, err := c.Get(ctx, analyticsKey).Int64()
 	if err == redis.Nil {
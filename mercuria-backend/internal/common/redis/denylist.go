@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RevokeToken adds a token's jti to the denylist until the token would have
+// expired anyway. The denylist is shared, so every service sees revocations.
+func (c *Client) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil // Already expired
+	}
+
+	key := c.keys.Shared("auth", "denylist", jti)
+	if err := c.Set(ctx, key, "revoked", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	c.logger.Debugf("Token revoked: %s", jti)
+	return nil
+}
+
+// IsTokenRevoked reports whether a token's jti is on the denylist
+func (c *Client) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	key := c.keys.Shared("auth", "denylist", jti)
+
+	exists, err := c.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token denylist: %w", err)
+	}
+
+	return exists > 0, nil
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SaveFXQuote stores an encoded FX quote until it expires
+func (c *Client) SaveFXQuote(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	key := c.keys.Key("fx", "quote", id)
+	if err := c.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save fx quote: %w", err)
+	}
+	return nil
+}
+
+// GetFXQuote returns an FX quote without using it up. It returns nil if the
+// quote does not exist or has expired.
+func (c *Client) GetFXQuote(ctx context.Context, id string) ([]byte, error) {
+	key := c.keys.Key("fx", "quote", id)
+	data, err := c.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fx quote: %w", err)
+	}
+	return data, nil
+}
+
+// TakeFXQuote returns and deletes an FX quote, so each quote is used at most
+// once. It returns nil if the quote does not exist or has expired.
+func (c *Client) TakeFXQuote(ctx context.Context, id string) ([]byte, error) {
+	key := c.keys.Key("fx", "quote", id)
+	data, err := c.GetDel(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take fx quote: %w", err)
+	}
+	return data, nil
+}
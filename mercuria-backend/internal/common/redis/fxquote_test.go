@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFXQuote(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	defer client.Del(ctx, client.Keys().Key("fx", "quote", "quote-1"))
+
+	if err := client.SaveFXQuote(ctx, "quote-1", []byte(`{"id":"quote-1"}`), time.Minute); err != nil {
+		t.Fatalf("SaveFXQuote failed: %v", err)
+	}
+
+	// Reading a quote leaves it in place
+	for i := 0; i < 2; i++ {
+		data, err := client.GetFXQuote(ctx, "quote-1")
+		if err != nil {
+			t.Fatalf("GetFXQuote failed: %v", err)
+		}
+		if string(data) != `{"id":"quote-1"}` {
+			t.Errorf("Expected the saved quote, got %q", data)
+		}
+	}
+
+	data, err := client.TakeFXQuote(ctx, "quote-1")
+	if err != nil {
+		t.Fatalf("TakeFXQuote failed: %v", err)
+	}
+	if string(data) != `{"id":"quote-1"}` {
+		t.Errorf("Expected the saved quote, got %q", data)
+	}
+
+	// A quote can only be taken once
+	data, err = client.TakeFXQuote(ctx, "quote-1")
+	if err != nil {
+		t.Fatalf("TakeFXQuote failed: %v", err)
+	}
+	if data != nil {
+		t.Errorf("Expected no quote on the second take, got %q", data)
+	}
+	if data, err := client.GetFXQuote(ctx, "quote-1"); err != nil || data != nil {
+		t.Errorf("Expected no quote after the take, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// KeyBuilder namespaces Redis keys so services and environments can share one
+// Redis without colliding. Keys have the form:
+//
+//	<prefix>:<service>:<parts...>  service scope (idempotency, rate limits, analytics)
+//	<prefix>:<parts...>            shared scope (wallet locks and balances)
+//
+// The prefix is usually "mercuria:<env>". With an empty prefix and service the
+// builder produces the legacy un-prefixed keys.
+type KeyBuilder struct {
+	prefix  string
+	service string
+}
+
+func NewKeyBuilder(prefix, service string) KeyBuilder {
+	return KeyBuilder{prefix: prefix, service: service}
+}
+
+// Key builds a key in the service namespace
+func (k KeyBuilder) Key(parts ...string) string {
+	return joinKey(append([]string{k.prefix, k.service}, parts...))
+}
+
+// Shared builds a key in the environment namespace, visible to every service
+func (k KeyBuilder) Shared(parts ...string) string {
+	return joinKey(append([]string{k.prefix}, parts...))
+}
+
+// Tag wraps id in a cluster hash tag, so keys built around the same id land in
+// the same slot
+func Tag(id string) string {
+	return "{" + id + "}"
+}
+
+// Pattern returns a SCAN pattern matching every key in the service namespace
+func (k KeyBuilder) Pattern() string {
+	return escapePattern(joinKey([]string{k.prefix, k.service})) + ":*"
+}
+
+// SharedPattern returns a SCAN pattern matching every key in the environment,
+// including all service namespaces
+func (k KeyBuilder) SharedPattern() string {
+	return escapePattern(k.prefix) + ":*"
+}
+
+func joinKey(parts []string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, ":")
+}
+
+// escapePattern escapes glob characters so a prefix is matched literally
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Keys returns the key builder the client's helpers use
+func (c *Client) Keys() KeyBuilder {
+	return c.keys
+}
+
+// ScanKeys calls fn with batches of keys matching pattern. It uses SCAN, so it
+// does not block Redis like KEYS would, and walks every master in cluster mode.
+// Keys created or deleted during the scan may or may not be reported.
+// In cluster mode the masters are scanned in parallel, so fn may be called
+// concurrently and must be safe for that.
+func (c *Client) ScanKeys(ctx context.Context, pattern string, batchSize int64, fn func(keys []string) error) error {
+	scan := func(ctx context.Context, node redis.UniversalClient) error {
+		iter := node.Scan(ctx, 0, pattern, batchSize).Iterator()
+
+		batch := make([]string, 0, batchSize)
+		for iter.Next(ctx) {
+			batch = append(batch, iter.Val())
+			if int64(len(batch)) >= batchSize {
+				if err := fn(batch); err != nil {
+					return err
+				}
+				batch = make([]string, 0, batchSize)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to scan keys: %w", err)
+		}
+
+		if len(batch) > 0 {
+			return fn(batch)
+		}
+		return nil
+	}
+
+	if cluster, ok := c.UniversalClient.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	}
+
+	return scan(ctx, c.UniversalClient)
+}
+
+// DeleteKeys removes every key matching pattern in batches and returns how
+// many were found. With dryRun set nothing is deleted, which makes it safe to
+// check what a cleanup would touch first, e.g. DeleteKeys(ctx, "lock:*", true)
+// to find keys written before namespacing was introduced.
+func (c *Client) DeleteKeys(ctx context.Context, pattern string, dryRun bool) (int64, error) {
+	var count atomic.Int64
+
+	err := c.ScanKeys(ctx, pattern, 500, func(keys []string) error {
+		count.Add(int64(len(keys)))
+		if dryRun {
+			return nil
+		}
+
+		// One UNLINK per key: in cluster mode the keys span slots
+		_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Unlink(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete keys: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return count.Load(), err
+	}
+
+	if dryRun {
+		c.logger.Infof("Dry run: %d keys match %s", count.Load(), pattern)
+	} else {
+		c.logger.Infof("Deleted %d keys matching %s", count.Load(), pattern)
+	}
+
+	return count.Load(), nil
+}
+
+// DeleteNamespace removes every key in the client's service namespace.
+// Shared keys are left alone, since other services still use them.
+func (c *Client) DeleteNamespace(ctx context.Context, dryRun bool) (int64, error) {
+	if c.keys.service == "" {
+		return 0, fmt.Errorf("refusing to delete keys without a service namespace")
+	}
+	return c.DeleteKeys(ctx, c.keys.Pattern(), dryRun)
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"github.com/kmassidik/mercuria/internal/common/logger"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	keys := NewKeyBuilder("mercuria:staging", "wallet")
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"service key", keys.Key("idempotency", Tag("req-1")), "mercuria:staging:wallet:idempotency:{req-1}"},
+		{"shared key", keys.Shared("lock", Tag("wallet-1")), "mercuria:staging:lock:{wallet-1}"},
+		{"service pattern", keys.Pattern(), "mercuria:staging:wallet:*"},
+		{"shared pattern", keys.SharedPattern(), "mercuria:staging:*"},
+		{"legacy key", NewKeyBuilder("", "").Key("analytics", "volume"), "analytics:volume"},
+		{"escaped pattern", NewKeyBuilder("app[1]", "svc").Pattern(), `app\[1\]:svc:*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+func TestDeleteNamespace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	cfg := config.RedisConfig{
+		Host:      "localhost",
+		Port:      "6379",
+		KeyPrefix: "mercuria:test",
+		Service:   "wallet",
+	}
+
+	log := logger.New("test")
+	client, err := Connect(cfg, log)
+	if err != nil {
+		t.Skip("Redis not available")
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	other := NewKeyBuilder("mercuria:test", "ledger").Key("idempotency", Tag("req-2"))
+	defer client.Del(ctx, other)
+
+	if err := client.SetIdempotency(ctx, "req-1", time.Minute); err != nil {
+		t.Fatalf("Failed to set idempotency: %v", err)
+	}
+	if err := client.IncrementCounter(ctx, "volume", time.Minute); err != nil {
+		t.Fatalf("Failed to increment counter: %v", err)
+	}
+	client.Set(ctx, other, "used", time.Minute)
+
+	count, err := client.DeleteNamespace(ctx, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 keys in namespace, got %d", count)
+	}
+
+	if _, err := client.DeleteNamespace(ctx, false); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+
+	exists, err := client.CheckIdempotency(ctx, "req-1")
+	if err != nil {
+		t.Fatalf("Failed to check idempotency: %v", err)
+	}
+	if exists {
+		t.Error("Namespace keys should be deleted")
+	}
+
+	// Other services' keys are untouched
+	if n, _ := client.Exists(ctx, other).Result(); n != 1 {
+		t.Error("Keys of other namespaces should be kept")
+	}
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLockMetrics(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	key := "test:lock-metrics"
+	defer client.ReleaseLock(ctx, key)
+
+	acquired := metrics.RedisLockAcquisitions.WithLabelValues("acquired")
+	contended := metrics.RedisLockAcquisitions.WithLabelValues("contended")
+	acquiredBefore, contendedBefore := testutil.ToFloat64(acquired), testutil.ToFloat64(contended)
+
+	client.AcquireLock(ctx, key, 5*time.Second)
+	client.AcquireLock(ctx, key, 5*time.Second)
+
+	if got := testutil.ToFloat64(acquired) - acquiredBefore; got != 1 {
+		t.Errorf("Expected 1 acquired lock, got %v", got)
+	}
+	if got := testutil.ToFloat64(contended) - contendedBefore; got != 1 {
+		t.Errorf("Expected 1 contended lock, got %v", got)
+	}
+}
+
+func TestPoolStatsCollector(t *testing.T) {
+	client := newTestClient(t)
+
+	if count := testutil.CollectAndCount(poolStatsCollector{client: client.UniversalClient}); count != 6 {
+		t.Errorf("Expected 6 pool metrics, got %d", count)
+	}
+
+	// Connect registers the collector
+	if count, err := testutil.GatherAndCount(metrics.Registry, "redis_pool_connections"); err != nil || count != 1 {
+		t.Errorf("Expected redis_pool_connections to be registered, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the oldest request in the window expires,
+	// which is also how long a rejected caller has to wait.
+	ResetAfter time.Duration
+}
+
+// slidingWindowScript implements a sliding window log: each request is a
+// sorted set member scored by its timestamp, and members older than the
+// window are trimmed before counting.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset}
+`)
+
+// AllowRequest records a request against key and reports whether it fits in
+// limit requests per window
+func (c *Client) AllowRequest(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error) {
+	rateLimitKey := c.keys.Key("ratelimit", key)
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+
+	res, err := slidingWindowScript.Run(ctx, c, []string{rateLimitKey}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	result := RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		ResetAfter: time.Duration(res[2]) * time.Millisecond,
+	}
+
+	if !result.Allowed {
+		c.logger.Debugf("Rate limit exceeded: %s", rateLimitKey)
+	}
+
+	return result, nil
+}
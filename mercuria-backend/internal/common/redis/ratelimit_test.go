@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAllowRequest(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	key := "test:ip:127.0.0.1"
+	defer client.Del(ctx, "ratelimit:"+key)
+
+	for i := 0; i < 3; i++ {
+		result, err := client.AllowRequest(ctx, key, 3, time.Minute)
+		if err != nil {
+			t.Fatalf("AllowRequest failed: %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+		if result.Remaining != 2-i {
+			t.Errorf("Expected %d remaining, got %d", 2-i, result.Remaining)
+		}
+	}
+
+	result, err := client.AllowRequest(ctx, key, 3, time.Minute)
+	if err != nil {
+		t.Fatalf("AllowRequest failed: %v", err)
+	}
+	if result.Allowed {
+		t.Error("Fourth request should be rejected")
+	}
+	if result.ResetAfter <= 0 || result.ResetAfter > time.Minute {
+		t.Errorf("Expected reset within the window, got %v", result.ResetAfter)
+	}
+}
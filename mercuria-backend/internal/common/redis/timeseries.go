@@ -0,0 +1,234 @@
+package redis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Granularity is the width of a time series bucket
+type Granularity string
+
+const (
+	Hourly Granularity = "hour"
+	Daily  Granularity = "day"
+)
+
+// Bucket is the value of a metric over one hour or day
+type Bucket struct {
+	Start       time.Time
+	Granularity Granularity
+	Value       int64
+}
+
+// incrementBucketsScript bumps the hourly and daily buckets of a metric in one
+// round trip. Like IncrementCounter, TTLs are only set on the first increment.
+var incrementBucketsScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	redis.call('INCRBY', key, ARGV[1])
+	if redis.call('PTTL', key) < 0 then
+		redis.call('PEXPIRE', key, ARGV[i + 1])
+	end
+end
+return 1
+`)
+
+// addUniqueScript is the HyperLogLog counterpart of incrementBucketsScript
+var addUniqueScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	redis.call('PFADD', key, ARGV[1])
+	if redis.call('PTTL', key) < 0 then
+		redis.call('PEXPIRE', key, ARGV[i + 1])
+	end
+end
+return 1
+`)
+
+// TimeSeries keeps hourly and daily analytics buckets in Redis.
+// Buckets are aligned to UTC and expire on their own; Flush copies them into
+// Postgres rollups for long-term storage.
+type TimeSeries struct {
+	client    *Client
+	hourlyTTL time.Duration
+	dailyTTL  time.Duration
+}
+
+func NewTimeSeries(client *Client, hourlyTTL, dailyTTL time.Duration) *TimeSeries {
+	return &TimeSeries{
+		client:    client,
+		hourlyTTL: hourlyTTL,
+		dailyTTL:  dailyTTL,
+	}
+}
+
+// BucketStart returns the start of the bucket containing t
+func BucketStart(t time.Time, g Granularity) time.Time {
+	t = t.UTC()
+	if g == Daily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+func nextBucket(t time.Time, g Granularity) time.Time {
+	if g == Daily {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(time.Hour)
+}
+
+func bucketStarts(g Granularity, from, to time.Time) []time.Time {
+	var starts []time.Time
+	for t := BucketStart(from, g); !t.After(to); t = nextBucket(t, g) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+func (ts *TimeSeries) bucketKey(kind, metric string, g Granularity, start time.Time) string {
+	layout := "2006010215"
+	if g == Daily {
+		layout = "20060102"
+	}
+	// The metric is the hash tag, so all buckets of a metric share a cluster slot
+	// and can be read with MGET/PFCOUNT or updated from one script.
+	return ts.client.keys.Key("analytics", kind, Tag(metric), string(g), start.Format(layout))
+}
+
+// Incr adds delta to the hourly and daily buckets containing at
+func (ts *TimeSeries) Incr(ctx context.Context, metric string, at time.Time, delta int64) error {
+	keys := []string{
+		ts.bucketKey("counter", metric, Hourly, BucketStart(at, Hourly)),
+		ts.bucketKey("counter", metric, Daily, BucketStart(at, Daily)),
+	}
+
+	err := incrementBucketsScript.Run(ctx, ts.client, keys, delta, ts.hourlyTTL.Milliseconds(), ts.dailyTTL.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to increment %s buckets: %w", metric, err)
+	}
+
+	return nil
+}
+
+// Range returns every bucket between from and to, inclusive.
+// Buckets with no data have a zero value.
+func (ts *TimeSeries) Range(ctx context.Context, metric string, g Granularity, from, to time.Time) ([]Bucket, error) {
+	starts := bucketStarts(g, from, to)
+	if len(starts) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = ts.bucketKey("counter", metric, g, start)
+	}
+
+	values, err := ts.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s buckets: %w", metric, err)
+	}
+
+	buckets := make([]Bucket, len(starts))
+	for i, start := range starts {
+		buckets[i] = Bucket{Start: start, Granularity: g}
+
+		if s, ok := values[i].(string); ok {
+			value, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s bucket value %q: %w", metric, s, err)
+			}
+			buckets[i].Value = value
+		}
+	}
+
+	return buckets, nil
+}
+
+// AddUnique records member (e.g. a user ID) in the HyperLogLogs of the
+// hourly and daily buckets containing at
+func (ts *TimeSeries) AddUnique(ctx context.Context, metric string, at time.Time, member string) error {
+	keys := []string{
+		ts.bucketKey("unique", metric, Hourly, BucketStart(at, Hourly)),
+		ts.bucketKey("unique", metric, Daily, BucketStart(at, Daily)),
+	}
+
+	err := addUniqueScript.Run(ctx, ts.client, keys, member, ts.hourlyTTL.Milliseconds(), ts.dailyTTL.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add unique %s: %w", metric, err)
+	}
+
+	return nil
+}
+
+// CountUnique returns the approximate number of distinct members recorded
+// between from and to, inclusive
+func (ts *TimeSeries) CountUnique(ctx context.Context, metric string, g Granularity, from, to time.Time) (int64, error) {
+	starts := bucketStarts(g, from, to)
+	if len(starts) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = ts.bucketKey("unique", metric, g, start)
+	}
+
+	count, err := ts.client.PFCount(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unique %s: %w", metric, err)
+	}
+
+	return count, nil
+}
+
+// Flush writes the buckets between from and to into the metric_rollups table.
+// Rows hold absolute values, so flushing the same bucket twice is harmless.
+// NOTE: Only flush buckets that are complete, or later increments are lost
+// once the Redis keys expire.
+func (ts *TimeSeries) Flush(ctx context.Context, db *sql.DB, metric string, g Granularity, from, to time.Time) error {
+	buckets, err := ts.Range(ctx, metric, g, from, to)
+	if err != nil {
+		return err
+	}
+
+	uniques := make([]*redis.IntCmd, len(buckets))
+	_, err = ts.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, b := range buckets {
+			uniques[i] = pipe.PFCount(ctx, ts.bucketKey("unique", metric, g, b.Start))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count unique %s: %w", metric, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO metric_rollups (metric, granularity, bucket_start, value, unique_count)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (metric, granularity, bucket_start)
+		DO UPDATE SET value = EXCLUDED.value, unique_count = EXCLUDED.unique_count, updated_at = CURRENT_TIMESTAMP
+	`
+
+	for i, b := range buckets {
+		if _, err := tx.ExecContext(ctx, query, metric, string(g), b.Start, b.Value, uniques[i].Val()); err != nil {
+			return fmt.Errorf("failed to flush %s bucket %s: %w", metric, b.Start, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+
+	ts.client.logger.Debugf("Flushed %d %s buckets for %s", len(buckets), g, metric)
+	return nil
+}
@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	metric := "test_volume"
+
+	at := time.Date(2025, 11, 10, 14, 30, 0, 0, time.UTC)
+
+	ts := NewTimeSeries(client, 48*time.Hour, 90*24*time.Hour)
+	defer client.Del(ctx,
+		ts.bucketKey("counter", metric, Hourly, BucketStart(at, Hourly)),
+		ts.bucketKey("counter", metric, Daily, BucketStart(at, Daily)),
+		ts.bucketKey("unique", metric, Hourly, BucketStart(at, Hourly)),
+		ts.bucketKey("unique", metric, Daily, BucketStart(at, Daily)),
+	)
+
+	if err := ts.Incr(ctx, metric, at, 100); err != nil {
+		t.Fatalf("Incr failed: %v", err)
+	}
+	if err := ts.Incr(ctx, metric, at.Add(10*time.Minute), 50); err != nil {
+		t.Fatalf("Incr failed: %v", err)
+	}
+
+	buckets, err := ts.Range(ctx, metric, Hourly, at.Add(-time.Hour), at.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(buckets) != 3 {
+		t.Fatalf("Expected 3 hourly buckets, got %d", len(buckets))
+	}
+	if buckets[0].Value != 0 || buckets[1].Value != 150 || buckets[2].Value != 0 {
+		t.Errorf("Unexpected hourly values: %+v", buckets)
+	}
+
+	daily, err := ts.Range(ctx, metric, Daily, at, at)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(daily) != 1 || daily[0].Value != 150 {
+		t.Errorf("Expected daily value 150, got %+v", daily)
+	}
+
+	// TTL is set once and not pushed back by later increments
+	key := ts.bucketKey("counter", metric, Daily, BucketStart(at, Daily))
+	ttl, err := client.TTL(ctx, key).Result()
+	if err != nil {
+		t.Fatalf("TTL failed: %v", err)
+	}
+	if ttl <= 0 || ttl > 90*24*time.Hour {
+		t.Errorf("Expected daily bucket TTL within 90 days, got %v", ttl)
+	}
+
+	for _, user := range []string{"user-1", "user-2", "user-1"} {
+		if err := ts.AddUnique(ctx, metric, at, user); err != nil {
+			t.Fatalf("AddUnique failed: %v", err)
+		}
+	}
+
+	count, err := ts.CountUnique(ctx, metric, Daily, at, at)
+	if err != nil {
+		t.Fatalf("CountUnique failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 unique users, got %d", count)
+	}
+}
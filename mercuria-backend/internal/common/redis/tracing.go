@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook records a client span per command or pipeline. Only commands
+// inside a trace get a span, so background polling does not create a root
+// trace per command. Arguments are not recorded; they include tokens and keys
+// derived from user data.
+type tracingHook struct{}
+
+var _ redis.Hook = tracingHook{}
+
+// hookSpanKey marks the span started by the hook, so AfterProcess never ends
+// the caller's span
+type hookSpanKey struct{}
+
+func (tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return startSpan(ctx, cmd.FullName(), strings.ToUpper(cmd.Name())), nil
+}
+
+func (tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return startSpan(ctx, "pipeline", "PIPELINE"), nil
+}
+
+func (tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && cmdErr != redis.Nil {
+			err = cmdErr
+			break
+		}
+	}
+	endSpan(ctx, err)
+	return nil
+}
+
+func startSpan(ctx context.Context, name, operation string) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(operation)),
+	)
+	return context.WithValue(ctx, hookSpanKey{}, span)
+}
+
+// endSpan ends the span started by startSpan. A redis.Nil reply is a cache
+// miss, not an error.
+func endSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(hookSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestTracingHook(t *testing.T) {
+	client := newTestClient(t)
+	exporter := tracing.SetupInMemory("test")
+	key := client.Keys().Key("test", "tracing")
+
+	// Outside a trace no span is recorded
+	if err := client.Set(context.Background(), key, "1", 0).Err(); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Errorf("Expected no spans outside a trace, got %d", len(exporter.GetSpans()))
+	}
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "request")
+	client.Get(ctx, key)
+	client.Get(ctx, key+":missing") // redis.Nil is a miss, not an error
+	parent.End()
+	client.Del(context.Background(), key)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 2 command spans and the parent, got %d", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name != "get" {
+			t.Errorf("Expected span name get, got %q", span.Name)
+		}
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected command span to be a child of the request span")
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("Expected no error status, got %v", span.Status)
+		}
+	}
+}
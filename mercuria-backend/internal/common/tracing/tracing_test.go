@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"none", config.TracingExporterNone, false},
+		{"stdout", config.TracingExporterStdout, false},
+		{"otlp", config.TracingExporterOTLP, false}, // Connects lazily
+		{"unknown", "zipkin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), config.TracingConfig{
+				Exporter:     tt.exporter,
+				OTLPEndpoint: "localhost:4318",
+				OTLPInsecure: true,
+				SampleRatio:  1,
+			}, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestSetupInMemory(t *testing.T) {
+	exporter := SetupInMemory("wallet")
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	_, child := Tracer().Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("Expected child span to have parent span as parent")
+	}
+
+	found := false
+	for _, attr := range spans[0].Resource.Attributes() {
+		if attr == semconv.ServiceName("wallet") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected service.name=wallet resource, got %v", spans[0].Resource.Attributes())
+	}
+
+	// The propagator round-trips the span context through headers
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	extracted := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), carrier))
+	if extracted.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Expected trace ID %s, got %s", parent.SpanContext().TraceID(), extracted.TraceID())
+	}
+}
@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+)
+
+// DefaultMaxBodyBytes limits request bodies decoded by DecodeJSON
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// DecodeJSON decodes a JSON request body into dst, limited to
+// DefaultMaxBodyBytes. See DecodeJSONLimit.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	return DecodeJSONLimit(w, r, dst, DefaultMaxBodyBytes)
+}
+
+// DecodeJSONLimit decodes a JSON request body of at most maxBytes into dst.
+// Unknown fields, trailing data and a Content-Type other than JSON are
+// rejected. The returned error is an *apierror.Error ready for apierror.Write.
+func DecodeJSONLimit(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return apierror.New(apierror.CodeUnsupportedMedia, "Content-Type must be application/json")
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, maxBytes)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err, maxBytes)
+		}
+		return apierror.BadRequest("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// decodeError maps a json.Decoder error to an API error
+func decodeError(err error, maxBytes int64) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &maxErr):
+		return apierror.New(apierror.CodeTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBytes))
+	case errors.Is(err, io.EOF):
+		return apierror.BadRequest("request body is empty")
+	case errors.As(err, &syntaxErr):
+		return apierror.BadRequest(fmt.Sprintf("malformed JSON at position %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.BadRequest("malformed JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apierror.Validation(apierror.FieldError{
+			Field:   typeErr.Field,
+			Code:    CodeInvalidType,
+			Message: fmt.Sprintf("%s must be a JSON %s", typeErr.Field, jsonType(typeErr.Type)),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			field = unquoted
+		}
+		return apierror.Validation(apierror.FieldError{
+			Field:   field,
+			Code:    CodeUnknownField,
+			Message: field + " is not a known field",
+		})
+	default:
+		return apierror.BadRequest("invalid request body").Wrap(err)
+	}
+}
+
+// jsonType names the JSON type that decodes into t
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,227 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+	"github.com/kmassidik/mercuria/internal/common/money"
+)
+
+// Field error codes
+const (
+	CodeRequired      = "required"
+	CodeInvalidFormat = "invalid_format"
+	CodeInvalidType   = "invalid_type"
+	CodeUnknownField  = "unknown_field"
+	CodeNotPositive   = "not_positive"
+	CodeTooPrecise    = "too_precise"
+	CodeTooLarge      = "too_large"
+	CodeOutOfRange    = "out_of_range"
+	CodeUnsupported   = "unsupported_currency"
+	CodeInvalid       = "invalid"
+)
+
+// Pagination bounds
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+	MaxPageOffset    = 10000
+)
+
+// maxAmountDigits bounds the integer part of an amount, so any valid amount
+// fits a money.Money in minor units
+const maxAmountDigits = 15
+
+var (
+	amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+	uuidPattern   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// Violation explains why a value is invalid. Message is a predicate, such
+// as "must be a UUID", that reads after the field name.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return "value " + v.Message
+}
+
+// ValidateCurrency checks that value is a supported ISO 4217 code
+func ValidateCurrency(value string) error {
+	if value == "" {
+		return &Violation{Code: CodeRequired, Message: "is required"}
+	}
+	if !money.IsSupported(value) {
+		return &Violation{
+			Code:    CodeUnsupported,
+			Message: fmt.Sprintf("must be one of %s", strings.Join(money.Currencies(), ", ")),
+		}
+	}
+	return nil
+}
+
+// ValidateAmount checks that value is a positive decimal string, such as
+// "1000.00", with no more decimal places than the currency allows. The scale
+// is not checked for an unsupported currency; ValidateCurrency reports that.
+func ValidateAmount(value, currency string) error {
+	if value == "" {
+		return &Violation{Code: CodeRequired, Message: "is required"}
+	}
+	if !amountPattern.MatchString(value) {
+		return &Violation{Code: CodeInvalidFormat, Message: `must be a decimal string such as "1000.00"`}
+	}
+
+	whole, frac, _ := strings.Cut(value, ".")
+	whole = strings.TrimLeft(whole, "0")
+	if len(whole) > maxAmountDigits {
+		return &Violation{Code: CodeTooLarge, Message: fmt.Sprintf("must have at most %d integer digits", maxAmountDigits)}
+	}
+	if whole == "" && strings.Trim(frac, "0") == "" {
+		return &Violation{Code: CodeNotPositive, Message: "must be greater than zero"}
+	}
+	if scale, ok := money.Scale(currency); ok && len(frac) > scale {
+		return &Violation{Code: CodeTooPrecise, Message: fmt.Sprintf("must have at most %d decimal places for %s", scale, currency)}
+	}
+	return nil
+}
+
+// ValidateUUID checks that value is a UUID in canonical form
+func ValidateUUID(value string) error {
+	if value == "" {
+		return &Violation{Code: CodeRequired, Message: "is required"}
+	}
+	if !uuidPattern.MatchString(value) {
+		return &Violation{Code: CodeInvalidFormat, Message: "must be a UUID"}
+	}
+	return nil
+}
+
+// ParseTimestamp parses an RFC 3339 timestamp, such as "2024-01-15T10:00:00Z"
+func ParseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, &Violation{Code: CodeRequired, Message: "is required"}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, &Violation{Code: CodeInvalidFormat, Message: "must be an RFC 3339 timestamp such as 2024-01-15T10:00:00Z"}
+	}
+	return t, nil
+}
+
+// Page is a validated limit/offset pair
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Validator collects field errors so a request reports every invalid field
+// at once. Err returns them as an apierror validation error.
+type Validator struct {
+	fields []apierror.FieldError
+}
+
+// New creates an empty validator
+func New() *Validator {
+	return &Validator{}
+}
+
+// Add records an invalid field
+func (v *Validator) Add(field, code, message string) {
+	v.fields = append(v.fields, apierror.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Check records err against field, if it is not nil. A *Violation keeps its
+// code; any other error is reported as "invalid".
+func (v *Validator) Check(field string, err error) {
+	if err == nil {
+		return
+	}
+	var violation *Violation
+	if errors.As(err, &violation) {
+		v.Add(field, violation.Code, field+" "+violation.Message)
+		return
+	}
+	v.Add(field, CodeInvalid, err.Error())
+}
+
+// Valid reports whether no field errors were recorded
+func (v *Validator) Valid() bool {
+	return len(v.fields) == 0
+}
+
+// Err returns the recorded field errors as an *apierror.Error, or nil
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return apierror.Validation(v.fields...)
+}
+
+// Required checks that value is not empty
+func (v *Validator) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, CodeRequired, field+" is required")
+	}
+}
+
+// Amount validates a decimal amount in currency
+func (v *Validator) Amount(field, value, currency string) {
+	v.Check(field, ValidateAmount(value, currency))
+}
+
+// Currency validates a currency code
+func (v *Validator) Currency(field, value string) {
+	v.Check(field, ValidateCurrency(value))
+}
+
+// UUID validates an identifier such as a wallet or transaction ID
+func (v *Validator) UUID(field, value string) {
+	v.Check(field, ValidateUUID(value))
+}
+
+// Timestamp validates and parses an RFC 3339 timestamp
+func (v *Validator) Timestamp(field, value string) time.Time {
+	t, err := ParseTimestamp(value)
+	v.Check(field, err)
+	return t
+}
+
+// Page validates the limit and offset query parameters, applying
+// DefaultPageLimit when limit is absent
+func (v *Validator) Page(query url.Values) Page {
+	return Page{
+		Limit:  v.queryInt(query, "limit", DefaultPageLimit, 1, MaxPageLimit),
+		Offset: v.queryInt(query, "offset", 0, 0, MaxPageOffset),
+	}
+}
+
+func (v *Validator) queryInt(query url.Values, field string, def, min, max int) int {
+	raw := query.Get(field)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		v.Add(field, CodeInvalidFormat, field+" must be an integer")
+		return def
+	}
+	if n < min || n > max {
+		v.Add(field, CodeOutOfRange, fmt.Sprintf("%s must be between %d and %d", field, min, max))
+		return def
+	}
+	return n
+}
+
+// Index returns the path of an element of an array field, e.g.
+// Index("recipients", 2) + ".amount" is "recipients[2].amount"
+func Index(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
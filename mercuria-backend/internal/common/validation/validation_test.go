@@ -0,0 +1,283 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kmassidik/mercuria/internal/common/apierror"
+)
+
+func TestValidateAmount(t *testing.T) {
+	tests := []struct {
+		name         string
+		amount       string
+		currency     string
+		expectedCode string
+	}{
+		{"whole amount", "1000", "USD", ""},
+		{"cents", "1000.00", "USD", ""},
+		{"smallest unit", "0.01", "EUR", ""},
+		{"JPY whole", "500", "JPY", ""},
+		{"empty", "", "USD", CodeRequired},
+		{"negative", "-10.00", "USD", CodeInvalidFormat},
+		{"exponent", "1e3", "USD", CodeInvalidFormat},
+		{"thousands separator", "1,000.00", "USD", CodeInvalidFormat},
+		{"trailing dot", "10.", "USD", CodeInvalidFormat},
+		{"zero", "0.00", "USD", CodeNotPositive},
+		{"too many decimals", "10.001", "USD", CodeTooPrecise},
+		{"JPY decimals", "500.5", "JPY", CodeTooPrecise},
+		{"too large", "1234567890123456", "IDR", CodeTooLarge},
+		{"leading zeros do not count", "0001234567890.00", "IDR", ""},
+		{"unsupported currency skips scale", "10.001", "XYZ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAmount(tt.amount, tt.currency)
+			if code := violationCode(err); code != tt.expectedCode {
+				t.Errorf("Expected code %q, got %q (%v)", tt.expectedCode, code, err)
+			}
+		})
+	}
+}
+
+func TestValidateCurrency(t *testing.T) {
+	tests := []struct {
+		currency     string
+		expectedCode string
+	}{
+		{"USD", ""},
+		{"EUR", ""},
+		{"GBP", ""},
+		{"JPY", ""},
+		{"IDR", ""},
+		{"", CodeRequired},
+		{"usd", CodeUnsupported},
+		{"CHF", CodeUnsupported},
+		{"US Dollar", CodeUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			err := ValidateCurrency(tt.currency)
+			if code := violationCode(err); code != tt.expectedCode {
+				t.Errorf("Expected code %q, got %q (%v)", tt.expectedCode, code, err)
+			}
+		})
+	}
+}
+
+func TestValidateUUID(t *testing.T) {
+	tests := []struct {
+		value        string
+		expectedCode string
+	}{
+		{"3f2b8c1e-9d4a-4f6b-8e2a-1c5d7f9b0a3e", ""},
+		{"3F2B8C1E-9D4A-4F6B-8E2A-1C5D7F9B0A3E", ""},
+		{"", CodeRequired},
+		{"wallet-123", CodeInvalidFormat},
+		{"3f2b8c1e9d4a4f6b8e2a1c5d7f9b0a3e", CodeInvalidFormat},
+		{"{3f2b8c1e-9d4a-4f6b-8e2a-1c5d7f9b0a3e}", CodeInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := ValidateUUID(tt.value)
+			if code := violationCode(err); code != tt.expectedCode {
+				t.Errorf("Expected code %q, got %q (%v)", tt.expectedCode, code, err)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value        string
+		expectedCode string
+	}{
+		{"2024-01-15T10:00:00Z", ""},
+		{"2024-01-15T10:00:00.123+07:00", ""},
+		{"", CodeRequired},
+		{"2024-01-15", CodeInvalidFormat},
+		{"2024-01-15 10:00:00", CodeInvalidFormat},
+		{"1705312800", CodeInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseTimestamp(tt.value)
+			if code := violationCode(err); code != tt.expectedCode {
+				t.Errorf("Expected code %q, got %q (%v)", tt.expectedCode, code, err)
+			}
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedLimit  int
+		expectedOffset int
+		expectedField  string
+	}{
+		{"defaults", "", DefaultPageLimit, 0, ""},
+		{"explicit", "limit=50&offset=100", 50, 100, ""},
+		{"max limit", "limit=100", 100, 0, ""},
+		{"limit too large", "limit=101", DefaultPageLimit, 0, "limit"},
+		{"zero limit", "limit=0", DefaultPageLimit, 0, "limit"},
+		{"negative offset", "offset=-1", DefaultPageLimit, 0, "offset"},
+		{"offset too large", "offset=10001", DefaultPageLimit, 0, "offset"},
+		{"not a number", "limit=ten", DefaultPageLimit, 0, "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			v := New()
+			page := v.Page(query)
+
+			if page.Limit != tt.expectedLimit || page.Offset != tt.expectedOffset {
+				t.Errorf("Expected limit %d offset %d, got %d %d", tt.expectedLimit, tt.expectedOffset, page.Limit, page.Offset)
+			}
+
+			fields := fieldErrors(t, v.Err())
+			if tt.expectedField == "" {
+				if len(fields) != 0 {
+					t.Errorf("Expected no errors, got %v", fields)
+				}
+				return
+			}
+			if len(fields) != 1 || fields[0].Field != tt.expectedField {
+				t.Errorf("Expected an error on %s, got %v", tt.expectedField, fields)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	v := New()
+	v.Required("description", "  ")
+	v.Currency("currency", "JPY")
+	v.Amount(Index("recipients", 2)+".amount", "12.50", "JPY")
+	v.UUID("wallet_id", "not-a-uuid")
+	v.Timestamp("scheduled_at", "2024-01-15T10:00:00Z")
+	v.Check("note", errors.New("note contains a control character"))
+
+	err := v.Err()
+	if !errors.Is(err, apierror.ErrValidation) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	fields := fieldErrors(t, err)
+	expected := []apierror.FieldError{
+		{Field: "description", Code: CodeRequired, Message: "description is required"},
+		{Field: "recipients[2].amount", Code: CodeTooPrecise, Message: "recipients[2].amount must have at most 0 decimal places for JPY"},
+		{Field: "wallet_id", Code: CodeInvalidFormat, Message: "wallet_id must be a UUID"},
+		{Field: "note", Code: CodeInvalid, Message: "note contains a control character"},
+	}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d field errors, got %v", len(expected), fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], fields[i])
+		}
+	}
+
+	if err := New().Err(); err != nil {
+		t.Errorf("Expected nil error for a valid request, got %v", err)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type transferRequest struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+		Count    int    `json:"count"`
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		maxBytes       int64
+		expectedStatus int
+		expectedField  string
+	}{
+		{"valid", `{"amount":"10.00","currency":"USD"}`, "application/json", 0, 0, ""},
+		{"charset", `{"amount":"10.00"}`, "application/json; charset=utf-8", 0, 0, ""},
+		{"no content type", `{"amount":"10.00"}`, "", 0, 0, ""},
+		{"empty body", ``, "application/json", 0, http.StatusBadRequest, ""},
+		{"malformed", `{"amount":`, "application/json", 0, http.StatusBadRequest, ""},
+		{"syntax error", `{"amount" "10.00"}`, "application/json", 0, http.StatusBadRequest, ""},
+		{"unknown field", `{"amount":"10.00","fee":"1.00"}`, "application/json", 0, http.StatusUnprocessableEntity, "fee"},
+		{"wrong type", `{"amount":10.00}`, "application/json", 0, http.StatusUnprocessableEntity, "amount"},
+		{"trailing data", `{"amount":"10.00"}{"amount":"20.00"}`, "application/json", 0, http.StatusBadRequest, ""},
+		{"too large", `{"amount":"` + strings.Repeat("1", 100) + `"}`, "application/json", 64, http.StatusRequestEntityTooLarge, ""},
+		{"form body", `amount=10.00`, "application/x-www-form-urlencoded", 0, http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/transfers", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			maxBytes := tt.maxBytes
+			if maxBytes == 0 {
+				maxBytes = DefaultMaxBodyBytes
+			}
+
+			var dst transferRequest
+			err := DecodeJSONLimit(rr, req, &dst, maxBytes)
+
+			if tt.expectedStatus == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if dst.Amount != "10.00" {
+					t.Errorf("Expected amount 10.00, got %q", dst.Amount)
+				}
+				return
+			}
+
+			apiErr := apierror.From(err)
+			if apiErr.Status != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d (%v)", tt.expectedStatus, apiErr.Status, err)
+			}
+			if tt.expectedField != "" && (len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != tt.expectedField) {
+				t.Errorf("Expected an error on %s, got %v", tt.expectedField, apiErr.Fields)
+			}
+		})
+	}
+}
+
+func violationCode(err error) string {
+	var violation *Violation
+	if errors.As(err, &violation) {
+		return violation.Code
+	}
+	if err != nil {
+		return "unexpected: " + err.Error()
+	}
+	return ""
+}
+
+func fieldErrors(t *testing.T, err error) []apierror.FieldError {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *apierror.Error, got %T", err)
+	}
+	return apiErr.Fields
+}
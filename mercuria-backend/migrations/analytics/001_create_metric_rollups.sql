@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS metric_rollups (
+    metric VARCHAR(100) NOT NULL,
+    granularity VARCHAR(10) NOT NULL,
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    unique_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (metric, granularity, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metric_rollups_bucket ON metric_rollups(granularity, bucket_start);
+
+-- +goose Down
+DROP TABLE IF EXISTS metric_rollups;
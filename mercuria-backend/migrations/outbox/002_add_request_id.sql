@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS request_id VARCHAR(128);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_request_id ON outbox_events(request_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_outbox_request_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS request_id;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS trace_context JSONB;
+
+-- +goose Down
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS trace_context;
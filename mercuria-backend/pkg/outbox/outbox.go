@@ -9,6 +9,14 @@ import (
 
 	"github.com/kmassidik/mercuria/internal/common/kafka"
 	"github.com/kmassidik/mercuria/internal/common/logger"
+	"github.com/kmassidik/mercuria/internal/common/metrics"
+	"github.com/kmassidik/mercuria/internal/common/requestid"
+	"github.com/kmassidik/mercuria/internal/common/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OutboxEvent represents an event waiting to be published to Kafka
@@ -38,6 +46,8 @@ type OutboxEvent struct {
     LastError    sql.NullString         `json:"last_error"`    // <-- FIX: Changed to sql.NullString
     CreatedAt    time.Time              `json:"created_at"`
     PublishedAt  sql.NullTime           `json:"published_at"`  // <-- GOOD PRACTICE: Changed from *time.Time
+    RequestID    string                 `json:"request_id,omitempty"` // Request that caused the event, sent as a Kafka header
+    TraceContext map[string]string      `json:"trace_context,omitempty"` // W3C trace context of the request, continued when publishing
 }
 
 const (
@@ -61,15 +71,34 @@ func NewRepository(db *sql.DB, log *logger.Logger) *Repository {
 // SaveEvent saves an event to the outbox table within a transaction
 // NOTE: This is called INSIDE your business transaction to ensure atomicity
 // Example: When depositing to wallet, save deposit event in same transaction
+// The event's RequestID and TraceContext default to those in ctx.
 func (r *Repository) SaveEvent(ctx context.Context, tx *sql.Tx, event *OutboxEvent) error {
 	payloadJSON, err := json.Marshal(event.Payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
+	if event.RequestID == "" {
+		event.RequestID, _ = requestid.FromContext(ctx)
+	}
+	if event.TraceContext == nil {
+		carrier := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+		if len(carrier) > 0 {
+			event.TraceContext = carrier
+		}
+	}
+
+	var traceContextJSON []byte // NULL when there is no trace
+	if event.TraceContext != nil {
+		if traceContextJSON, err = json.Marshal(event.TraceContext); err != nil {
+			return fmt.Errorf("failed to marshal trace context: %w", err)
+		}
+	}
+
 	query := `
-		INSERT INTO outbox_events (aggregate_id, event_type, topic, payload, status, attempts)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO outbox_events (aggregate_id, event_type, topic, payload, status, attempts, request_id, trace_context)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 
@@ -85,13 +114,15 @@ func (r *Repository) SaveEvent(ctx context.Context, tx *sql.Tx, event *OutboxEve
 		payloadJSON,
 		event.Status,
 		event.Attempts,
+		sql.NullString{String: event.RequestID, Valid: event.RequestID != ""},
+		traceContextJSON,
 	).Scan(&event.ID, &event.CreatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to save outbox event: %w", err)
 	}
 
-	r.logger.Debugf("Outbox event saved: %s for aggregate %s", event.EventType, event.AggregateID)
+	r.logger.DebugContext(ctx, fmt.Sprintf("Outbox event saved: %s for aggregate %s", event.EventType, event.AggregateID))
 	return nil
 }
 
@@ -99,7 +130,7 @@ func (r *Repository) SaveEvent(ctx context.Context, tx *sql.Tx, event *OutboxEve
 // NOTE: This is called by the background worker to publish events to Kafka
 func (r *Repository) GetPendingEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
 	query := `
-        SELECT id, aggregate_id, event_type, topic, payload, status, attempts, last_error, created_at, published_at
+        SELECT id, aggregate_id, event_type, topic, payload, status, attempts, last_error, created_at, published_at, request_id, trace_context
         FROM outbox_events
         WHERE status = $1 AND attempts < 5
         ORDER BY created_at ASC
@@ -119,6 +150,8 @@ func (r *Repository) GetPendingEvents(ctx context.Context, limit int) ([]OutboxE
 		// Variables for nullable fields
         var lastError sql.NullString 
         var publishedAt sql.NullTime
+        var requestID sql.NullString
+        var traceContextJSON []byte
 		
 		err := rows.Scan(
             &event.ID,
@@ -131,6 +164,8 @@ func (r *Repository) GetPendingEvents(ctx context.Context, limit int) ([]OutboxE
             &lastError,          // Scan into sql.NullString
             &event.CreatedAt,
             &publishedAt,        // Scan into sql.NullTime
+            &requestID,
+            &traceContextJSON,
         )
 
 		if err != nil {
@@ -140,6 +175,12 @@ func (r *Repository) GetPendingEvents(ctx context.Context, limit int) ([]OutboxE
 		// Assign nullable variables back to the struct fields
         event.LastError = lastError
         event.PublishedAt = publishedAt
+        event.RequestID = requestID.String
+        if traceContextJSON != nil {
+            if err := json.Unmarshal(traceContextJSON, &event.TraceContext); err != nil {
+                return nil, fmt.Errorf("failed to unmarshal trace context: %w", err)
+            }
+        }
 		
 		// Unmarshal payload
         if err := json.Unmarshal(payloadJSON, &event.Payload); err != nil {
@@ -211,6 +252,20 @@ func (r *Repository) IncrementAttempt(ctx context.Context, eventID string, error
 	return nil
 }
 
+// CountPending returns the number of events waiting to be published
+func (r *Repository) CountPending(ctx context.Context) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM outbox_events
+		WHERE status = $1 AND attempts < 5
+	`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, StatusPending).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending events: %w", err)
+	}
+	return count, nil
+}
+
 // Publisher is responsible for publishing outbox events to Kafka
 // NOTE: This runs as a background worker, polling the outbox table
 type Publisher struct {
@@ -253,6 +308,13 @@ func (p *Publisher) Start(ctx context.Context) {
 // publishPendingEvents fetches and publishes pending events
 // NOTE: This is the core outbox processing logic
 func (p *Publisher) publishPendingEvents(ctx context.Context) error {
+	// Report the backlog before each batch, for the pending depth gauge
+	if pending, err := p.repo.CountPending(ctx); err == nil {
+		metrics.OutboxPendingEvents.Set(float64(pending))
+	} else {
+		p.logger.Warnf("Failed to count pending events: %v", err)
+	}
+
 	// Get pending events (limit to 100 per batch)
 	events, err := p.repo.GetPendingEvents(ctx, 100)
 	if err != nil {
@@ -266,25 +328,50 @@ func (p *Publisher) publishPendingEvents(ctx context.Context) error {
 	p.logger.Infof("Publishing %d pending events", len(events))
 
 	for _, event := range events {
-		// Publish to Kafka
-		err := p.producer.PublishEvent(ctx, event.Topic, event.AggregateID, event.Payload)
-		if err != nil {
-			// Increment attempt counter
-			p.logger.Errorf("Failed to publish event %s: %v", event.ID, err)
-			
-			if event.Attempts >= 4 { // Max 5 attempts (0-4)
-				p.repo.MarkAsFailed(ctx, event.ID, err.Error())
-			} else {
-				p.repo.IncrementAttempt(ctx, event.ID, err.Error())
-			}
-			continue
-		}
+		p.publishEvent(ctx, event)
+	}
+
+	return nil
+}
+
+// publishEvent publishes one event to Kafka under the request ID and trace of
+// the request that caused it, so consumers continue that trace
+func (p *Publisher) publishEvent(ctx context.Context, event OutboxEvent) {
+	eventCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.TraceContext))
+	if event.RequestID != "" {
+		eventCtx = requestid.NewContext(eventCtx, event.RequestID)
+	}
+
+	eventCtx, span := tracing.Tracer().Start(eventCtx, "outbox publish "+event.Topic,
+		trace.WithAttributes(
+			attribute.String("outbox.event_id", event.ID),
+			attribute.String("outbox.event_type", event.EventType),
+			attribute.Int("outbox.attempts", event.Attempts),
+		),
+	)
+	defer span.End()
 
-		// Mark as published
-		if err := p.repo.MarkAsPublished(ctx, event.ID); err != nil {
-			p.logger.Errorf("Failed to mark event as published: %v", err)
+	err := p.producer.PublishEvent(eventCtx, event.Topic, event.AggregateID, event.Payload)
+	if err != nil {
+		metrics.OutboxPublishFailures.WithLabelValues(event.Topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+
+		// Increment attempt counter
+		p.logger.ErrorContext(eventCtx, fmt.Sprintf("Failed to publish event %s", event.ID), "error", err)
+
+		if event.Attempts >= 4 { // Max 5 attempts (0-4)
+			metrics.OutboxEventsFailed.WithLabelValues(event.Topic).Inc()
+			p.repo.MarkAsFailed(eventCtx, event.ID, err.Error())
+		} else {
+			p.repo.IncrementAttempt(eventCtx, event.ID, err.Error())
 		}
+		return
 	}
+	metrics.OutboxPublishLatency.WithLabelValues(event.Topic).Observe(time.Since(event.CreatedAt).Seconds())
 
-	return nil
+	// Mark as published
+	if err := p.repo.MarkAsPublished(eventCtx, event.ID); err != nil {
+		p.logger.ErrorContext(eventCtx, "Failed to mark event as published", "error", err)
+	}
 }
\ No newline at end of file
@@ -18,6 +18,9 @@ TOPICS=(
     "transaction.failed"
     "ledger.entry_created"
     "user.created"
+    "user.login_failed"
+    "user.locked"
+    "user.mfa_enabled"
 )
 
 # Function to create a topic
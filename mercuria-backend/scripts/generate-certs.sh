@@ -0,0 +1,20 @@
+#!/bin/bash
+# scripts/generate-certs.sh
+
+set -e
+
+# Output directory and lifetimes (override via environment)
+CERTS_DIR=${CERTS_DIR:-"certs"}
+CA_LIFETIME=${CA_LIFETIME:-"8760h"}
+CERT_LIFETIME=${CERT_LIFETIME:-"720h"}
+
+echo "🔐 Generating mTLS certificates in ./${CERTS_DIR}..."
+
+# Extra arguments are passed through, e.g. --services wallet or --new-ca
+go run ./cmd/gencerts \
+    --out "${CERTS_DIR}" \
+    --ca-lifetime "${CA_LIFETIME}" \
+    --cert-lifetime "${CERT_LIFETIME}" \
+    "$@"
+
+echo "✅ Certificates ready. Point MTLS_CA_CERT at ${CERTS_DIR}/ca/ca.crt"
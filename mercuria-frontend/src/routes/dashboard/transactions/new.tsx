@@ -50,6 +50,7 @@ function NewTransactionPage() {
         from_wallet_id: fromWalletId,
         to_wallet_id: toWalletId,
         amount,
+        currency: selectedWallet?.currency ?? "",
         description,
         idempotency_key: generateIdempotencyKey(),
       },
@@ -1,48 +1,50 @@
+// Money fields are decimal strings, e.g. "1000.00"; never parse them into floats.
+
 export interface DailyMetric {
   id: number;
   metric_date: string;
   total_transactions: number;
-  total_volume: number;
-  total_fees: number;
+  total_volume: string;
+  total_fees: string;
   unique_users: number;
   successful_transactions: number;
   failed_transactions: number;
-  avg_transaction_value: number;
+  avg_transaction_value: string;
 }
 
 export interface HourlyMetric {
   id: number;
   metric_hour: string;
   total_transactions: number;
-  total_volume: number;
-  total_fees: number;
+  total_volume: string;
+  total_fees: string;
   unique_users: number;
   successful_transactions: number;
   failed_transactions: number;
-  avg_transaction_value: number;
-  max_transaction_value: number;
-  min_transaction_value: number;
+  avg_transaction_value: string;
+  max_transaction_value: string;
+  min_transaction_value: string;
   avg_processing_time_ms: number;
 }
 
 export interface MetricsSummary {
   period: string;
   total_transactions: number;
-  total_volume: number;
-  total_fees: number;
+  total_volume: string;
+  total_fees: string;
   unique_users: number;
   success_rate: number;
-  avg_transaction_size: number;
+  avg_transaction_size: string;
 }
 
 export interface UserAnalytics {
   user_id: string;
   period: string;
-  total_sent: number;
-  total_received: number;
-  net_amount: number;
+  total_sent: string;
+  total_received: string;
+  net_amount: string;
   transaction_count: number;
-  total_fees_paid: number;
+  total_fees_paid: string;
   last_transaction_at?: string;
 }
 
@@ -50,11 +52,11 @@ export interface UserSnapshot {
   id: number;
   user_id: string;
   snapshot_date: string;
-  total_sent: number;
-  total_received: number;
+  total_sent: string;
+  total_received: string;
   transaction_count: number;
   sent_count: number;
   received_count: number;
-  total_fees_paid: number;
+  total_fees_paid: string;
   last_transaction_at?: string;
 }
@@ -1,6 +1,15 @@
+export interface ApiFieldError {
+  field: string;
+  code: string;
+  message: string;
+}
+
 export interface ApiError {
   error: string;
-  message?: string;
+  code: string;
+  message: string;
+  details?: ApiFieldError[];
+  request_id?: string;
 }
 
 export interface PaginatedResponse<T> {
@@ -11,6 +11,10 @@ export interface Transaction {
   scheduled_at?: string;
   processed_at?: string;
   failure_reason?: string;
+  target_amount?: string;
+  target_currency?: string;
+  fx_rate?: string;
+  fx_quote_id?: string;
   created_at: string;
   updated_at: string;
 }
@@ -19,10 +23,34 @@ export interface CreateTransactionRequest {
   from_wallet_id: string;
   to_wallet_id: string;
   amount: string;
+  currency: string;
+  fx_quote_id?: string;
   description?: string;
   idempotency_key: string;
 }
 
+export interface Money {
+  amount: string;
+  currency: string;
+}
+
+export interface CreateFxQuoteRequest {
+  amount: string;
+  currency: string;
+  target_currency: string;
+}
+
+export interface FxQuote {
+  id: string;
+  user_id: string;
+  source: Money;
+  target: Money;
+  rate: string;
+  mid_rate: string;
+  created_at: string;
+  expires_at: string;
+}
+
 export interface BatchTransferItem {
   to_wallet_id: string;
   amount: string;